@@ -1,33 +1,37 @@
 package main
 
 import (
-	"crypto/aes"
 	"crypto/ecdsa"
 	"crypto/ed25519"
-	"encoding/binary"
 	"fmt"
 	"net"
+	"time"
 
-	"github.com/ProtonMail/go-crypto/eax"
 	gofsm "github.com/looplab/fsm"
 
+	"github.com/bzp2010/ts3protocol/tsproto/connection"
+	"github.com/bzp2010/ts3protocol/tsproto/crypto"
 	"github.com/bzp2010/ts3protocol/tsproto/license"
 	"github.com/bzp2010/ts3protocol/tsproto/packets"
-)
-
-var (
-	defaultKey   = []byte{0x63, 0x3A, 0x5C, 0x77, 0x69, 0x6E, 0x64, 0x6F, 0x77, 0x73, 0x5C, 0x73, 0x79, 0x73, 0x74, 0x65}
-	defaultNonce = []byte{0x6D, 0x5C, 0x66, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6C, 0x6C, 0x33, 0x32, 0x2E, 0x63, 0x70, 0x6C}
+	"github.com/bzp2010/ts3protocol/tsproto/puzzle"
 )
 
 type client struct {
 	*gofsm.FSM
 	Conn          *net.UDPConn
 	RemoteAddr    *net.UDPAddr
-	PacketCounter *packetCounter
+	PacketCounter *connection.PacketCounter
+	Generations   *connection.GenerationWindow
+	Keepalive     *connection.Keepalive
+	SendQueue     *connection.SendQueue
+	// the receive window and reassembler of Command and CommandLow
+	ReceiveWindows map[packets.PacketType]*connection.ReceiveWindow
+	Reassemblers   map[packets.PacketType]*packets.Reassembler
 
-	SharedIV  []byte
-	SharedMAC []byte
+	Crypto *crypto.Crypt
+
+	Puzzle      *puzzle.Puzzle
+	PuzzleLevel uint32
 
 	ServerPrivateKey     ed25519.PrivateKey
 	ClientOmegaPublicKey *ecdsa.PublicKey
@@ -36,61 +40,84 @@ type client struct {
 	TempLicense          *license.License
 }
 
-func (c client) Send(p packets.Packet) error {
-	var (
-		headerRaw []byte
-		bodyRaw   []byte
-	)
+// Send marshals and seals the packet for the current crypto phase,
+// commands are kept until acked and resent
+func (c client) Send(p packets.Packet, generation uint32) error {
+	raw, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	raw, err = c.Crypto.Seal(raw, generation, packets.PacketDirectionS2C)
+	if err != nil {
+		return err
+	}
 
-	switch p.(type) {
-	case *packets.CommandPacket:
-		cp := p.(*packets.CommandPacket)
+	if cp, ok := p.(*packets.CommandPacket); ok {
 		fmt.Println("准备发送Command数据包", cp.Command.Name, cp.Command.Params)
-		headerRaw, _ = cp.S2C.Marshal()
-		bodyRaw, _ = cp.Command.Marshal()
-		if cp.S2C.Encrypted {
-			headerRaw, bodyRaw = encrypt(headerRaw, bodyRaw)
-		}
-
-	case *packets.AckPacket:
-		ap := p.(*packets.AckPacket)
-		fmt.Println("准备发送Ack数据包")
-		headerRaw, _ = ap.S2C.Marshal()
-		bodyRaw = make([]byte, 2)
-		binary.BigEndian.PutUint16(bodyRaw, ap.PacketId)
-		if ap.S2C.Encrypted {
-			headerRaw, bodyRaw = encrypt(headerRaw, bodyRaw)
+		if c.SendQueue != nil {
+			return c.SendQueue.Send(cp.S2C.PacketType, cp.S2C.PacketId, raw, time.Now())
 		}
 	}
+	return c.SendRaw(raw)
+}
 
-	_, err := c.Conn.WriteToUDP(append(headerRaw, bodyRaw...), c.RemoteAddr)
+// SendRaw implements connection.RawSender
+func (c client) SendRaw(raw []byte) error {
+	_, err := c.Conn.WriteToUDP(raw, c.RemoteAddr)
+	return err
+}
+
+// SendAck acknowledges a Command or CommandLow packet with the paired ack type
+func (c client) SendAck(sourceType packets.PacketType, sourcePacketId uint16) error {
+	ackType, ok := packets.AckTypeFor(sourceType)
+	if !ok {
+		return nil
+	}
+	ackId, generation, err := c.PacketCounter.Next(ackType)
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func (c client) SendAck(sourcePacketId uint16) error {
 	ack := &packets.AckPacket{
 		S2C: &packets.S2CPacket{
-			PacketId:    0,
+			PacketId:    ackId,
 			Encrypted:   true,
 			NewProtocol: true,
-			PacketType:  packets.PacketTypeAck,
+			PacketType:  ackType,
 		},
 		PacketId: sourcePacketId,
 	}
-	return c.Send(ack)
+	return c.Send(ack, generation)
 }
 
-func encrypt(headerRaw, bodyRaw []byte) ([]byte, []byte) {
-	block, _ := aes.NewCipher(defaultKey)
-	aead, _ := eax.NewEAXWithNonceAndTagSize(block, 16, 8)
-	ret := aead.Seal([]byte{}, defaultNonce, bodyRaw, headerRaw[8:])
-	for i := 0; i < 8; i++ {
-		headerRaw[i] = ret[len(bodyRaw)+i]
+// SendPing implements connection.KeepaliveSender
+func (c client) SendPing() (uint16, error) {
+	pingId, generation, err := c.PacketCounter.Next(packets.PacketTypePing)
+	if err != nil {
+		return 0, err
+	}
+	ping := &packets.PingPacket{
+		S2C: &packets.S2CPacket{
+			PacketId:   pingId,
+			Encrypted:  false,
+			PacketType: packets.PacketTypePing,
+		},
+	}
+	return pingId, c.Send(ping, generation)
+}
+
+// SendPong implements connection.KeepaliveSender
+func (c client) SendPong(pingId uint16) error {
+	pongId, generation, err := c.PacketCounter.Next(packets.PacketTypePong)
+	if err != nil {
+		return err
+	}
+	pong := &packets.PongPacket{
+		S2C: &packets.S2CPacket{
+			PacketId:   pongId,
+			Encrypted:  false,
+			PacketType: packets.PacketTypePong,
+		},
+		PingId: pingId,
 	}
-	bodyRaw = ret[:len(bodyRaw)]
-	return headerRaw, bodyRaw
+	return c.Send(pong, generation)
 }
@@ -2,44 +2,36 @@ package main
 
 import (
 	"bytes"
-	"crypto/aes"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha1"
 	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
-	"math/big"
 	"net"
 	"os"
+	"sync"
+	"time"
 
 	"filippo.io/edwards25519"
-	"github.com/ProtonMail/go-crypto/eax"
 	gofsm "github.com/looplab/fsm"
 
 	"github.com/bzp2010/ts3protocol/tsproto/commands"
+	"github.com/bzp2010/ts3protocol/tsproto/connection"
 	"github.com/bzp2010/ts3protocol/tsproto/crypto"
 	"github.com/bzp2010/ts3protocol/tsproto/license"
 	"github.com/bzp2010/ts3protocol/tsproto/packets"
+	"github.com/bzp2010/ts3protocol/tsproto/puzzle"
 )
 
-type packetCounter struct {
-	Command       uint16
-	CommandGen    uint32
-	CommandLow    uint16
-	CommandLowGen uint32
-	Ack           uint16
-	AckGen        uint32
-	AckLow        uint16
-	AckLowGen     uint32
-}
-
 var (
-	clientMap = make(map[string]*client)
+	clientMap   = make(map[string]*client)
+	clientMapMu sync.Mutex
+	puzzlePool  *puzzle.Pool
+	cookieJar                      = connection.NewCookieJar()
+	levelPolicy puzzle.LevelPolicy = puzzle.NewAdaptiveLevel()
 )
 
 func main() {
@@ -61,6 +53,9 @@ func main() {
 		os.Exit(1)
 	}
 	defer conn.Close()
+
+	puzzlePool = puzzle.NewPool(puzzle.DefaultPoolSize)
+	defer puzzlePool.Close()
 	for {
 		handleClient(conn)
 	}
@@ -75,58 +70,68 @@ func handleClient(conn *net.UDPConn) {
 		return
 	}
 
-	if _, ok := clientMap[remoteAddr.String()]; !ok {
-		fmt.Println("为", remoteAddr, "创建客户端实例")
-		clientMap[remoteAddr.String()] = &client{
-			FSM:        newFSM(),
-			Conn:       conn,
-			RemoteAddr: remoteAddr,
+	clientMapMu.Lock()
+	client, ok := clientMap[remoteAddr.String()]
+	clientMapMu.Unlock()
+	if !ok {
+		// no state is kept before INIT2 echoes a valid cookie
+		client = handleLowInit(conn, remoteAddr, data[:n])
+		if client == nil {
+			return
 		}
 	}
 
-	client := clientMap[remoteAddr.String()]
-
 	// parse C2S packet
 	c2sp := &packets.C2SPacket{}
 	_ = c2sp.Unmarshal(data[:n])
 
+	if client.Keepalive != nil {
+		switch c2sp.PacketType {
+		case packets.PacketTypePing:
+			if _, err := decryptC2S(client, data[:n]); err == nil {
+				_ = client.Keepalive.HandlePing(c2sp.PacketId)
+			}
+			return
+		case packets.PacketTypePong:
+			if payload, err := decryptC2S(client, data[:n]); err == nil && len(payload) >= 2 {
+				client.Keepalive.HandlePong(binary.BigEndian.Uint16(payload), time.Now())
+			}
+			return
+		}
+	}
+
 	if (client.FSM.Is("LOW_START") || client.FSM.Is("LOW_P1") || client.FSM.Is("LOW_P3")) && c2sp.MAC != "TS3INIT1" {
 		//fmt.Println("状态机状态错误，忽略数据包")
 		return
 	}
-	if c2sp.PacketType == packets.PacketTypeAck {
+	if c2sp.PacketType == packets.PacketTypeAck || c2sp.PacketType == packets.PacketTypeAckLow {
 		fmt.Println("接收到一个ACK包")
+		payload, err := decryptC2S(client, data[:n])
+		if err != nil {
+			fmt.Println("ACK包解密错误", err)
+			return
+		}
+		ack := &packets.AckPacket{C2S: c2sp}
+		if len(payload) >= 2 {
+			ack.PacketId = binary.BigEndian.Uint16(payload)
+			client.SendQueue.Ack(ack.AckedType(), ack.PacketId, time.Now())
+		}
 		return
 	}
 
 	fmt.Println("-------------------------------")
 	fmt.Println("RECEIVE", n, remoteAddr /*c2sp*/)
 
-	switch client.FSM.Current() {
-	case "LOW_START": // 初始状态，处理INIT0包
-		fmt.Println("PROCESS LOW_START")
-		init0 := &packets.Init0Packet{}
-		err := init0.Unmarshal(data[:n])
-		if err != nil {
-			fmt.Println("接收到错误INIT0", err)
-			os.Exit(0)
+	// the commands after the low level handshake go through the receive window
+	if c2sp.PacketType == packets.PacketTypeCommand || c2sp.PacketType == packets.PacketTypeCommandLow {
+		if client.FSM.Is("HIGH_InitIVExpand") || client.FSM.Is("HIGH_ClientInit") {
+			handleCommandPacket(client, data[:n])
+			fmt.Println("FSM next state", client.FSM.Current())
+			return
 		}
-		fmt.Println("接收到INIT0", init0)
+	}
 
-		//a0 := c2sp.Data[9:13]
-		random1 := make([]byte, 16)
-		rand.Read(random1)
-		init1 := &packets.Init1Packet{
-			Random0: init0.Random0,
-			Random1: *(*[16]byte)(random1),
-		}
-		init1raw, err := init1.Marshal()
-		if err != nil {
-			fmt.Println("编码出错误INIT1", err)
-		}
-		fmt.Println("发响应INIT1", init1, init1raw)
-		conn.WriteToUDP(init1raw, remoteAddr)
-		client.FSM.Event("E_LOW_P0")
+	switch client.FSM.Current() {
 	case "LOW_P1": // 处理INIT2包
 		fmt.Println("PROCESS LOW_P1")
 		init2 := &packets.Init2Packet{}
@@ -140,23 +145,19 @@ func handleClient(conn *net.UDPConn) {
 		// generate random value
 		stuff := make([]byte, 100)
 		rand.Read(stuff)
-		x := make([]byte, 64)
-		rand.Read(x)
-		n := make([]byte, 64)
-		rand.Read(n)
-		// use a fixed level value
-		level := uint32(10000)
+		p, err := puzzlePool.Get()
+		if err != nil {
+			fmt.Println("生成PUZZLE失败", err)
+			return
+		}
+		client.Puzzle = p
+		// the level rises with the handshake load and the history of the address
+		client.PuzzleLevel = levelPolicy.Level(remoteAddr.IP.String(), time.Now())
 
 		init3 := &packets.Init3Packet{
-			X:       *(*[64]byte)(x),
-			N:       *(*[64]byte)(n),
-			Level:   level,
 			Random2: *(*[100]byte)(stuff),
 		}
-
-		//fmt.Println("服务器生成的X值", (&big.Int{}).SetBytes(x[0:]))
-		//fmt.Println("服务器生成的N值", (&big.Int{}).SetBytes(n[0:]))
-		//fmt.Println("服务器生成的L值", level)
+		p.FillInit3(init3, client.PuzzleLevel)
 
 		init3raw, err := init3.Marshal()
 		if err != nil {
@@ -176,28 +177,11 @@ func handleClient(conn *net.UDPConn) {
 		fmt.Println("接收到INIT4", init4)
 
 		// check puzzle result
-		x := &big.Int{}
-		x.SetBytes(init4.X[0:])
-		n := &big.Int{}
-		n.SetBytes(init4.N[0:])
-		y := &big.Int{}
-		y.SetBytes(init4.Y[0:])
-		level := init4.Level
-
-		//fmt.Println("客户端发回的X值", x)
-		//fmt.Println("客户端发回的N值", n)
-		//fmt.Println("客户端发回的L值", level)
-
-		// t = 2 ^ level
-		t := (&big.Int{}).Exp(big.NewInt(2), big.NewInt(int64(level)), nil)
-		// cy = x ^ t % n
-		cy := x.Exp(x, t, n)
-
-		//fmt.Println("客户端发回的Y值", y)
-		//fmt.Println("解算PUZZLE结果", cy)
-		if y.Cmp(cy) != 0 {
+		solved := client.Puzzle.VerifyInit4(init4, client.PuzzleLevel)
+		levelPolicy.Done(remoteAddr.IP.String(), solved, time.Now())
+		if !solved {
 			fmt.Println("解算PUZZLE校验失败")
-			os.Exit(0)
+			return
 		}
 
 		// parse clientinitiv command
@@ -239,6 +223,12 @@ func handleClient(conn *net.UDPConn) {
 		}
 		fmt.Println("服务端私钥", serverPrivateKey)
 
+		// clients before 3.1 only know initivexpand
+		if !commands.SupportsInitIVExpand2(cmd) {
+			handleLegacyClientInitIV(client, serverPrivateKey)
+			break
+		}
+
 		lic := license.NewDefaultLicense()
 		licMarshaled, err := lic.Marshal()
 		fmt.Println("服务器License", licMarshaled)
@@ -251,7 +241,7 @@ func handleClient(conn *net.UDPConn) {
 			fmt.Println("生成ServerEKPrivateKey失败", err)
 		}*/
 		var serverPublicKey ed25519.PublicKey
-		client.ServerPrivateKey, serverPublicKey = lic.GetServerEK()
+		client.ServerPrivateKey, serverPublicKey, _ = lic.GetServerEK()
 		fmt.Print("服务器EK公钥")
 		fmt.Println(serverPublicKey)
 
@@ -260,9 +250,14 @@ func handleClient(conn *net.UDPConn) {
 			fmt.Println("创建InitIVExpand2命令失败", err)
 		}
 		client.TempBeta, _ = base64.StdEncoding.DecodeString(initivexpand2.Params["beta"])
+		commandId, generation, err := client.PacketCounter.Next(packets.PacketTypeCommand)
+		if err != nil {
+			fmt.Println("分配包ID失败", err)
+			return
+		}
 		initivexpand2Packet := packets.CommandPacket{
 			S2C: &packets.S2CPacket{
-				PacketId:    0,
+				PacketId:    commandId,
 				Encrypted:   true,
 				Compressed:  false,
 				NewProtocol: true,
@@ -272,29 +267,144 @@ func handleClient(conn *net.UDPConn) {
 			Command: initivexpand2,
 		}
 		fmt.Println("生成initivexpand2命令", initivexpand2Packet)
-		err = client.Send(&initivexpand2Packet)
+		// clientinitiv starts the fake encryption
+		client.Crypto.StartFake()
+		err = client.Send(&initivexpand2Packet, generation)
 		if err != nil {
 			fmt.Println("发响应initivexpand2失败", err)
 		}
+		client.SendQueue.Start()
 		client.FSM.Event("E_HIGH_ClientInitIV")
-	case "HIGH_InitIVExpand":
-		fmt.Println("接收到clientek原始数据", data[:n], "头部为", data[:13])
+	}
 
-		block, _ := aes.NewCipher(defaultKey)
-		aead, _ := eax.NewEAXWithNonceAndTagSize(block, 16, 8)
-		ret, err := aead.Open([]byte{}, defaultNonce, bytes.Join([][]byte{data[13:n], data[0:8]}, []byte{}), data[8:13])
+	fmt.Println("FSM next state", client.FSM.Current())
+}
+
+// handleLowInit answers INIT0 with a cookie in INIT1 without keeping state, and
+// creates the client once INIT2 echoes a valid cookie
+func handleLowInit(conn *net.UDPConn, remoteAddr *net.UDPAddr, raw []byte) *client {
+	c2sp := &packets.C2SPacket{}
+	if err := c2sp.Unmarshal(raw); err != nil || c2sp.MAC != "TS3INIT1" || len(raw) < 18 {
+		return nil
+	}
+
+	addr := remoteAddr.String()
+	switch raw[17] {
+	case 0x00:
+		init0 := &packets.Init0Packet{}
+		if err := init0.Unmarshal(raw); err != nil {
+			fmt.Println("接收到错误INIT0", err)
+			return nil
+		}
+		init1 := &packets.Init1Packet{
+			Random0: init0.Random0,
+			Random1: cookieJar.Issue(addr, init0.Random0, time.Now()),
+		}
+		init1raw, err := init1.Marshal()
 		if err != nil {
-			fmt.Println("数据包解密错误", err)
-			os.Exit(0)
+			fmt.Println("编码出错误INIT1", err)
+			return nil
 		}
-		fmt.Println("数据包解密后原始数据", string(ret))
-		cmd := &packets.Command{}
-		err = cmd.Unmarshal(ret)
+		conn.WriteToUDP(init1raw, remoteAddr)
+		return nil
+	case 0x02:
+		init2 := &packets.Init2Packet{}
+		if err := init2.Unmarshal(raw); err != nil {
+			fmt.Println("接收到错误INIT2", err)
+			return nil
+		}
+		if !cookieJar.Verify(addr, init2.Random0, init2.Random1, time.Now()) {
+			fmt.Println("INIT2 cookie校验失败", addr)
+			return nil
+		}
+
+		fmt.Println("为", remoteAddr, "创建客户端实例")
+		c := newClient(conn, remoteAddr)
+		clientMapMu.Lock()
+		if existing, ok := clientMap[addr]; ok {
+			c = existing
+		} else {
+			clientMap[addr] = c
+		}
+		clientMapMu.Unlock()
+		c.FSM.Event("E_LOW_P0")
+		return c
+	default:
+		return nil
+	}
+}
+
+// newClient creates the state of a client which passed the cookie check
+func newClient(conn *net.UDPConn, remoteAddr *net.UDPAddr) *client {
+	addr := remoteAddr.String()
+	c := &client{
+		FSM:           newFSM(),
+		Conn:          conn,
+		RemoteAddr:    remoteAddr,
+		PacketCounter: &connection.PacketCounter{},
+		Generations:   &connection.GenerationWindow{},
+		Crypto:        crypto.NewCrypt(),
+	}
+	c.SendQueue = connection.NewSendQueue(c, func() {
+		fmt.Println("客户端命令确认超时", addr)
+		dropClient(addr)
+	})
+	// the clientinitiv in INIT4 takes the Command id 0
+	c.ReceiveWindows = map[packets.PacketType]*connection.ReceiveWindow{
+		packets.PacketTypeCommand:    connection.NewReceiveWindow(packets.PacketTypeCommand, c),
+		packets.PacketTypeCommandLow: connection.NewReceiveWindow(packets.PacketTypeCommandLow, c),
+	}
+	c.ReceiveWindows[packets.PacketTypeCommand].Expect(1, 0)
+	c.Reassemblers = map[packets.PacketType]*packets.Reassembler{
+		packets.PacketTypeCommand:    {},
+		packets.PacketTypeCommandLow: {},
+	}
+	return c
+}
+
+// handleCommandPacket decrypts a Command or CommandLow packet, acks it and handles
+// the commands released by the receive window in packet id order
+func handleCommandPacket(client *client, raw []byte) {
+	header := &packets.C2SPacket{}
+	if err := header.Unmarshal(raw); err != nil {
+		fmt.Println("解码命令包错误", err)
+		return
+	}
+	generation, err := client.Generations.Infer(header.PacketType, header.PacketId)
+	if err != nil {
+		fmt.Println("未知的包类型", err)
+		return
+	}
+	plain, err := decryptC2S(client, raw)
+	if err != nil {
+		fmt.Println("数据包解密错误", err)
+		return
+	}
+	fmt.Println("数据包解密后原始数据", string(plain))
+
+	cp := &packets.CommandPacket{C2S: header, Data: plain}
+	ready, err := client.ReceiveWindows[header.PacketType].Push(cp, generation)
+	if err != nil {
+		fmt.Println("发送ACK失败", err)
+	}
+	for _, p := range ready {
+		cmd, err := client.Reassemblers[header.PacketType].Push(p)
 		if err != nil {
-			fmt.Println("接收到错误clientek", err)
-			os.Exit(0)
+			fmt.Println("命令重组错误", err)
+			continue
+		}
+		if cmd != nil {
+			handleCommand(client, cmd)
 		}
-		fmt.Println("接收到", cmd.Name, "命令，参数为", cmd.Params)
+	}
+}
+
+// handleCommand handles a complete command of the client
+func handleCommand(client *client, cmd *packets.Command) {
+	fmt.Println("接收到", cmd.Name, "命令，参数为", cmd.Params)
+
+	switch client.FSM.Current() {
+	case "HIGH_InitIVExpand":
 		if cmd.Name != "clientek" {
 			fmt.Println("接收到非clientek命令")
 			os.Exit(0)
@@ -324,108 +434,128 @@ func handleClient(conn *net.UDPConn) {
 		fmt.Println("proof签名校验通过")
 
 		// 计算 SharedIV 和 SharedMAC
-		client.SharedIV, client.SharedMAC, err = sharedSecret(client.ServerPrivateKey, clientEK, client.TempAlpha, client.TempBeta)
+		session, err := crypto.NewSession(client.ServerPrivateKey, clientEK, client.TempAlpha, client.TempBeta)
 		if err != nil {
 			fmt.Println("计算SharedIV等失败", err)
+			return
+		}
+		fmt.Println("计算SharedIV", session.SharedIV, "SharedMAC", session.SharedMAC)
+		if err = client.Crypto.Negotiate(session); err != nil {
+			fmt.Println("切换协商密钥失败", err)
+			return
 		}
-		fmt.Println("计算SharedIV", client.SharedIV, "SharedMAC", []byte(client.SharedMAC))
 
-		_ = client.SendAck(c2sp.PacketId)
 		client.FSM.Event("E_HIGH_ClientEK")
+		startKeepalive(client)
 	case "HIGH_ClientInit":
-		fmt.Println("接收到clientinit原始数据", data[:n], "头部为", data[:13])
-		cp := &packets.CommandPacket{}
-		err := cp.Unmarshal(data[:n])
-		if err != nil {
-			fmt.Println("解码clientinit错误", err)
-		}
-
-		key, nonce := calculateKeyAndNonce(packets.PacketTypeCommand, cp.C2S.PacketId, 0, packets.PacketDirectionC2S, client.SharedIV)
-
-		block, _ := aes.NewCipher(key)
-		aead, _ := eax.NewEAXWithNonceAndTagSize(block, 16, 8)
-		ret, err := aead.Open([]byte{}, nonce, append(data[13:n], data[0:8]...), data[8:13])
-		if err != nil {
-			fmt.Println("数据包解密错误", err)
-			//os.Exit(0)
-			return
-		}
-		fmt.Println("数据包解密后原始数据", string(ret))
+		fmt.Println("接收到clientinit", cmd.Params)
 	}
-
-	fmt.Println("FSM next state", client.FSM.Current())
 }
 
-func newFSM() *gofsm.FSM {
-	return gofsm.NewFSM(
-		"LOW_START",
-		gofsm.Events{
-			{Name: "E_LOW_P0", Src: []string{"LOW_START"}, Dst: "LOW_P1"},
-			{Name: "E_LOW_P2", Src: []string{"LOW_P1"}, Dst: "LOW_P3"},
-			{Name: "E_HIGH_ClientInitIV", Src: []string{"LOW_P3"}, Dst: "HIGH_InitIVExpand"},
-			{Name: "E_HIGH_ClientEK", Src: []string{"HIGH_InitIVExpand"}, Dst: "HIGH_ClientInit"},
-		},
-		gofsm.Callbacks{},
-	)
-}
-
-func sharedSecret(serverPrivateKey ed25519.PrivateKey, clientPublicKey ed25519.PublicKey, alpha, beta []byte) ([]byte, []byte, error) {
-	fmt.Println("计算共享密钥IV和MAC", serverPrivateKey, clientPublicKey, alpha, beta)
-	scalar, err := new(edwards25519.Scalar).SetCanonicalBytes(serverPrivateKey)
+// handleLegacyClientInitIV answers the clientinitiv of a client before 3.1 with
+// initivexpand, the keys come from the P-256 key exchange and there is no clientek
+func handleLegacyClientInitIV(client *client, serverPrivateKey *ecdsa.PrivateKey) {
+	initivexpand, err := commands.NewInitIVExpand(client.TempAlpha, serverPrivateKey)
 	if err != nil {
-		return nil, nil, err
+		fmt.Println("创建InitIVExpand命令失败", err)
+		return
 	}
-	point, err := new(edwards25519.Point).SetBytes(clientPublicKey)
+	beta, _ := base64.StdEncoding.DecodeString(initivexpand.Params["beta"])
+	session, err := crypto.NewLegacySession(serverPrivateKey, client.ClientOmegaPublicKey, client.TempAlpha, beta)
 	if err != nil {
-		return nil, nil, err
+		fmt.Println("计算SharedIV等失败", err)
+		return
 	}
-	sharedData := point.ScalarMult(scalar, point).Bytes()
-	fmt.Println("共享密钥数据", sharedData)
 
-	sharedIV := sha512.Sum512(sharedData[:32])
-
-	// Xor alpha
-	for i := range alpha {
-		sharedIV[i] ^= alpha[i]
+	commandId, generation, err := client.PacketCounter.Next(packets.PacketTypeCommand)
+	if err != nil {
+		fmt.Println("分配包ID失败", err)
+		return
 	}
-	// Xor
-	for i := range beta {
-		sharedIV[10+i] ^= beta[i]
+	initivexpandPacket := packets.CommandPacket{
+		S2C: &packets.S2CPacket{
+			PacketId:    commandId,
+			Encrypted:   true,
+			NewProtocol: true,
+			PacketType:  packets.PacketTypeCommand,
+		},
+		Command: initivexpand,
 	}
+	// initivexpand is still fake encrypted, the following packets use the negotiated keys
+	client.Crypto.StartFake()
+	err = client.Send(&initivexpandPacket, generation)
+	if err != nil {
+		fmt.Println("发响应initivexpand失败", err)
+	}
+	if err = client.Crypto.Negotiate(session); err != nil {
+		fmt.Println("切换协商密钥失败", err)
+		return
+	}
+	client.SendQueue.Start()
+	client.FSM.Event("E_HIGH_InitIVExpand")
+	startKeepalive(client)
+}
 
-	macHash := sha1.Sum(sharedIV[:])
-	sharedMAC := macHash[:8]
-	return sharedIV[:], sharedMAC, nil
+// startKeepalive drops the client when it stops answering pings
+func startKeepalive(client *client) {
+	addr := client.RemoteAddr.String()
+	client.Keepalive = connection.NewKeepalive(client, func() {
+		fmt.Println("客户端超时", addr)
+		dropClient(addr)
+	})
+	// the pongs tune the resend timeout of the commands
+	client.Keepalive.RTT = client.SendQueue.RTT
+	client.Keepalive.Start()
 }
 
-func calculateKeyAndNonce(t packets.PacketType, packetId uint16, generationId uint32, direction packets.PacketDirection, sharedIV []byte) ([]byte, []byte) {
-	temporary := make([]byte, 70)
-	if direction == packets.PacketDirectionS2C {
-		temporary[0] = 0x30
-	} else {
-		temporary[0] = 0x31
+// dropClient stops the timers of the client and forgets it
+func dropClient(addr string) {
+	clientMapMu.Lock()
+	c, ok := clientMap[addr]
+	delete(clientMap, addr)
+	clientMapMu.Unlock()
+	if !ok {
+		return
 	}
-	// packet type
-	temporary[1] = uint8(t)
-
-	// generation id
-	gId := make([]byte, 4)
-	binary.BigEndian.PutUint32(gId, generationId)
-	for i, b := range gId {
-		temporary[2+i] = b
+	c.SendQueue.Stop()
+	if c.Keepalive != nil {
+		c.Keepalive.Stop()
 	}
+}
 
-	// sharedIV
-	for i, b := range sharedIV {
-		temporary[6+i] = b
+// decryptC2S opens a C2S packet for the crypto phase of the client and returns
+// its payload
+func decryptC2S(client *client, raw []byte) ([]byte, error) {
+	header := &packets.C2SPacket{}
+	if err := header.Unmarshal(raw); err != nil {
+		return nil, err
 	}
 
-	// calculate key and nonce
-	keyNonce := sha256.Sum256(temporary)
-	key := keyNonce[:16]
-	nonce := keyNonce[16:32]
-	key[0] ^= byte((packetId & 0xFF00) >> 8)
-	key[1] ^= byte((packetId & 0x00FF) >> 0)
+	generation, err := client.Generations.Infer(header.PacketType, header.PacketId)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := client.Crypto.Open(raw, generation, packets.PacketDirectionC2S)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = client.Generations.Accept(header.PacketType, header.PacketId); err != nil {
+		return nil, err
+	}
+	return plain[packets.C2SHeaderSize:], nil
+}
 
-	return key, nonce
+func newFSM() *gofsm.FSM {
+	return gofsm.NewFSM(
+		"LOW_START",
+		gofsm.Events{
+			{Name: "E_LOW_P0", Src: []string{"LOW_START"}, Dst: "LOW_P1"},
+			{Name: "E_LOW_P2", Src: []string{"LOW_P1"}, Dst: "LOW_P3"},
+			{Name: "E_HIGH_ClientInitIV", Src: []string{"LOW_P3"}, Dst: "HIGH_InitIVExpand"},
+			{Name: "E_HIGH_ClientEK", Src: []string{"HIGH_InitIVExpand"}, Dst: "HIGH_ClientInit"},
+			// the legacy handshake has no clientek
+			{Name: "E_HIGH_InitIVExpand", Src: []string{"LOW_P3"}, Dst: "HIGH_ClientInit"},
+		},
+		gofsm.Callbacks{},
+	)
 }
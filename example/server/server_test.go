@@ -1,33 +0,0 @@
-package main
-
-import (
-	"testing"
-
-	"github.com/stretchr/testify/assert"
-)
-
-func TestSharedSecret1(t *testing.T) {
-	clientEK := []byte{33, 130, 36, 111, 230, 193, 88, 163, 84, 149, 169, 240, 34, 95, 60, 204, 237, 38, 11, 27, 90, 63, 197, 50, 83, 66, 0, 2, 160, 86, 131, 4}
-	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
-	alpha := []byte{126, 211, 35, 127, 243, 201, 91, 73, 76, 236}
-	beta := []byte{9, 175, 214, 76, 55, 7, 129, 96, 92, 61, 39, 36, 187, 26, 232, 62, 144, 168, 180, 221, 237, 205, 17, 219, 78, 149, 161, 51, 56, 172, 5, 249, 157, 219, 215, 62, 74, 193, 128, 157, 155, 9, 91, 255, 217, 111, 7, 65, 179, 212, 190, 218, 219, 33}
-	expectIV := []byte{148, 105, 180, 62, 107, 35, 13, 131, 151, 216, 140, 157, 127, 78, 88, 57, 126, 50, 148, 198, 66, 46, 23, 241, 238, 172, 143, 168, 119, 190, 67, 17, 14, 230, 193, 82, 82, 84, 192, 34, 11, 28, 250, 151, 96, 69, 248, 74, 54, 242, 129, 24, 142, 18, 62, 72, 187, 191, 172, 229, 154, 194, 224, 109}
-	expectMAC := []byte{226, 171, 133, 70, 51, 56, 72, 48}
-	iv, mac, err := sharedSecret(clientEK, serverEK, alpha, beta)
-	assert.NoError(t, err)
-	assert.Equal(t, expectIV, iv)
-	assert.Equal(t, expectMAC, []byte(mac))
-}
-
-func TestSharedSecret2(t *testing.T) {
-	clientEK := []byte{141, 13, 243, 209, 90, 237, 241, 67, 215, 37, 172, 156, 157, 64, 26, 67, 202, 4, 248, 243, 195, 201, 210, 116, 215, 205, 29, 96, 220, 131, 220, 12}
-	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
-	alpha := []byte{202, 230, 0, 52, 22, 180, 158, 138, 106, 250}
-	beta := []byte{104, 48, 226, 127, 0, 28, 191, 66, 77, 76, 42, 20, 26, 60, 175, 156, 46, 54, 37, 178, 83, 249, 250, 194, 17, 181, 34, 204, 121, 221, 212, 129, 41, 178, 25, 58, 192, 18, 80, 211, 90, 125, 31, 46, 111, 81, 247, 219, 43, 103, 62, 2, 80, 70}
-	expectIV := []byte{65, 141, 2, 3, 44, 254, 84, 249, 139, 150, 45, 139, 66, 94, 164, 208, 4, 19, 210, 65, 113, 157, 222, 190, 2, 49, 169, 240, 172, 52, 172, 242, 51, 90, 98, 100, 160, 242, 104, 240, 98, 178, 240, 170, 165, 229, 146, 188, 195, 35, 200, 113, 125, 5, 61, 254, 27, 244, 209, 70, 175, 142, 27, 117}
-	expectMAC := []byte{165, 35, 211, 116, 142, 52, 222, 251}
-	iv, mac, err := sharedSecret(clientEK, serverEK, alpha, beta)
-	assert.NoError(t, err)
-	assert.Equal(t, expectIV, iv)
-	assert.Equal(t, expectMAC, []byte(mac))
-}
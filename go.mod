@@ -8,6 +8,7 @@ require (
 	github.com/aead/ecdh v0.2.0
 	github.com/looplab/fsm v0.3.0
 	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.8.0
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 )
 
@@ -15,6 +16,5 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.4.0 // indirect
-	github.com/stretchr/testify v1.8.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
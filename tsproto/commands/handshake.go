@@ -1,13 +1,20 @@
 package commands
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"time"
+
+	"filippo.io/edwards25519"
 
 	ts3Crypto "github.com/bzp2010/ts3protocol/tsproto/crypto"
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 	"github.com/bzp2010/ts3protocol/tsproto/license"
 	"github.com/bzp2010/ts3protocol/tsproto/packets"
 )
@@ -28,7 +35,7 @@ func NewInitIVExpand2(license license.License, privateKey *ecdsa.PrivateKey) (*p
 
 	// encode public key
 	o := &ts3Crypto.ASN1Omega{
-		BS:         "0",
+		BS:         "\x00", // one bit 0, public key only
 		KeySize:    32,
 		PublicKeyX: privateKey.X,
 		PublicKeyY: privateKey.Y,
@@ -57,3 +64,149 @@ func NewInitIVExpand2(license license.License, privateKey *ecdsa.PrivateKey) (*p
 		},
 	}, nil
 }
+
+// SupportsInitIVExpand2 reports whether the client of clientinitiv supports the
+// license based initivexpand2 handshake, clients before 3.1 do not send ot=1 and
+// expect the legacy initivexpand
+func SupportsInitIVExpand2(clientinitiv *packets.Command) bool {
+	return clientinitiv.Params["ot"] == "1"
+}
+
+// NewInitIVExpand creates the initivexpand command of the legacy handshake, the
+// shared secret is the P-256 ECDH of the omega keys, see crypto.LegacySharedSecret
+func NewInitIVExpand(alpha []byte, privateKey *ecdsa.PrivateKey) (*packets.Command, error) {
+	// generate beta
+	beta := make([]byte, 10)
+	_, err := rand.Read(beta)
+	if err != nil {
+		return nil, err
+	}
+
+	// encode public key
+	o := &ts3Crypto.ASN1Omega{
+		BS:         "\x00", // one bit 0, public key only
+		KeySize:    32,
+		PublicKeyX: privateKey.X,
+		PublicKeyY: privateKey.Y,
+	}
+	omega, err := o.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &packets.Command{
+		Name: "initivexpand",
+		Params: map[string]string{
+			"alpha": base64.StdEncoding.EncodeToString(alpha), // alpha is the alpha of clientinitiv
+			"beta":  base64.StdEncoding.EncodeToString(beta),  // beta is base64(random[u8; 10]) by the server
+			"omega": base64.StdEncoding.EncodeToString(omega), // omega is base64(publicKey[u8]) with the public key from the server
+		},
+	}, nil
+}
+
+// NewClientEK creates the clientek command of the client, it generates an Ed25519
+// ephemeral keypair with a clamped private scalar and signs ek || beta with the
+// P-256 identity key. The returned private scalar is used for the shared secret
+// with the server ephemeral key from initivexpand2.
+func NewClientEK(identity *ecdsa.PrivateKey, beta []byte) (*packets.Command, []byte, error) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	scalar, err := new(edwards25519.Scalar).SetBytesWithClamping(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	ek := new(edwards25519.Point).ScalarBaseMult(scalar).Bytes()
+
+	// proof
+	hash := sha256.Sum256(bytes.Join([][]byte{ek, beta}, []byte{}))
+	proof, err := ecdsa.SignASN1(rand.Reader, identity, hash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &packets.Command{
+		Name: "clientek",
+		Params: map[string]string{
+			"ek":    base64.StdEncoding.EncodeToString(ek),    // ek is base64(publicKey[u8; 32]) of the client ephemeral key
+			"proof": base64.StdEncoding.EncodeToString(proof), // proof is base64(ecdsa_sign(ek || beta)) with the identity key
+		},
+	}, scalar.Bytes(), nil
+}
+
+// InitIVExpand2 is the validated content of an initivexpand2 command
+type InitIVExpand2 struct {
+	// var bytes : The license chain of the server, signed by the proof
+	License []byte
+	// 54 bytes : The beta generated by the server
+	Beta []byte
+	// The P-256 identity key of the server from omega
+	ServerPublicKey *ecdsa.PublicKey
+	// The Ed25519 ephemeral key of the server derived from the license chain
+	ServerEK ed25519.PublicKey
+}
+
+// ParseInitIVExpand2 parses the initivexpand2 command on the client, it verifies
+// the proof over sha256(l) with the omega key and walks the license chain valid at
+// now to the server ephemeral key
+func ParseInitIVExpand2(cmd *packets.Command, now time.Time) (*InitIVExpand2, error) {
+	if cmd.Name != "initivexpand2" {
+		return nil, tsErrors.Errorf(tsErrors.InvalidCommand, "expect initivexpand2 but "+cmd.Name)
+	}
+
+	params := make(map[string][]byte, 4)
+	for _, key := range []string{"l", "beta", "omega", "proof"} {
+		value, ok := cmd.Params[key]
+		if !ok {
+			return nil, tsErrors.Errorf(tsErrors.InvalidCommand, "initivexpand2 without "+key)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, tsErrors.Errorf(tsErrors.InvalidCommand, "initivexpand2 "+key+" is not base64")
+		}
+		params[key] = decoded
+	}
+	if len(params["beta"]) != 54 {
+		return nil, tsErrors.Errorf(tsErrors.InvalidCommand, "initivexpand2 beta is not 54 bytes")
+	}
+
+	// proof
+	o := &ts3Crypto.ASN1Omega{}
+	if err := o.Decode(params["omega"]); err != nil {
+		return nil, err
+	}
+	serverPublicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     o.PublicKeyX,
+		Y:     o.PublicKeyY,
+	}
+	if !serverPublicKey.Curve.IsOnCurve(serverPublicKey.X, serverPublicKey.Y) {
+		return nil, tsErrors.Errorf(tsErrors.InvalidASN1Omega, "public key not on curve")
+	}
+	hash := sha256.Sum256(params["l"])
+	if !ecdsa.VerifyASN1(serverPublicKey, hash[:], params["proof"]) {
+		return nil, tsErrors.Errorf(tsErrors.InvalidCommand, "initivexpand2 proof mismatch")
+	}
+
+	// license chain
+	lic := &license.License{}
+	if err := lic.Unmarshal(params["l"]); err != nil {
+		return nil, err
+	}
+	if lic.Blocks[len(lic.Blocks)-1].BlockType != license.BlockTypeEphemeral {
+		return nil, tsErrors.Errorf(tsErrors.InvalidLicense, "last block is not ephemeral")
+	}
+	serverEK, err := lic.Verify(now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InitIVExpand2{
+		License:         params["l"],
+		Beta:            params["beta"],
+		ServerPublicKey: serverPublicKey,
+		ServerEK:        serverEK,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ts3Crypto "github.com/bzp2010/ts3protocol/tsproto/crypto"
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/license"
+	"github.com/bzp2010/ts3protocol/tsproto/license/licensetest"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+func TestNewClientEK(t *testing.T) {
+	identity, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	beta := make([]byte, 54)
+	_, _ = rand.Read(beta)
+
+	cmd, privateKey, err := NewClientEK(identity, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, "clientek", cmd.Name)
+
+	ek, err := base64.StdEncoding.DecodeString(cmd.Params["ek"])
+	assert.NoError(t, err)
+	assert.Len(t, ek, 32)
+	proof, err := base64.StdEncoding.DecodeString(cmd.Params["proof"])
+	assert.NoError(t, err)
+
+	// the server verifies the proof over ek || beta
+	hash := sha256.Sum256(bytes.Join([][]byte{ek, beta}, []byte{}))
+	assert.True(t, ecdsa.VerifyASN1(&identity.PublicKey, hash[:], proof))
+	hash = sha256.Sum256(ek)
+	assert.False(t, ecdsa.VerifyASN1(&identity.PublicKey, hash[:], proof))
+
+	// both sides compute the same shared secret
+	serverCmd, serverPrivateKey, err := NewClientEK(identity, beta)
+	assert.NoError(t, err)
+	serverPublicKey, _ := base64.StdEncoding.DecodeString(serverCmd.Params["ek"])
+	alpha := make([]byte, 10)
+	clientIV, clientMAC, err := ts3Crypto.SharedSecret(privateKey, serverPublicKey, alpha, beta)
+	assert.NoError(t, err)
+	serverIV, serverMAC, err := ts3Crypto.SharedSecret(serverPrivateKey, ek, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, serverIV, clientIV)
+	assert.Equal(t, serverMAC, clientMAC)
+}
+
+func TestParseInitIVExpand2(t *testing.T) {
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	lic := license.NewDefaultLicense()
+	now := time.Now()
+
+	cmd, err := NewInitIVExpand2(lic, serverKey)
+	assert.NoError(t, err)
+	parsed, err := ParseInitIVExpand2(cmd, now)
+	assert.NoError(t, err)
+	assert.Equal(t, serverKey.PublicKey.X, parsed.ServerPublicKey.X)
+	assert.Equal(t, serverKey.PublicKey.Y, parsed.ServerPublicKey.Y)
+	assert.Len(t, parsed.Beta, 54)
+	l, err := lic.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, l, parsed.License)
+	_, serverEK, err := lic.GetServerEK()
+	assert.NoError(t, err)
+	assert.Equal(t, serverEK, parsed.ServerEK)
+
+	// omega holds the public key flag as one bit 0 with seven padding bits, a DER
+	// decoder rejects the bit string with non-zero padding
+	omega, err := base64.StdEncoding.DecodeString(cmd.Params["omega"])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x02, 0x07, 0x00}, omega[2:6])
+
+	// a tampered license does not match the proof
+	l[len(l)-5] ^= 0x01
+	tampered := &packets.Command{Name: cmd.Name, Params: map[string]string{}}
+	for k, v := range cmd.Params {
+		tampered.Params[k] = v
+	}
+	tampered.Params["l"] = base64.StdEncoding.EncodeToString(l)
+	_, err = ParseInitIVExpand2(tampered, now)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidCommand))
+
+	// a missing parameter
+	delete(tampered.Params, "proof")
+	_, err = ParseInitIVExpand2(tampered, now)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidCommand))
+
+	// the license chain is only valid in its validity window
+	_, err = ParseInitIVExpand2(cmd, time.Unix(license.ValidDataDifference-1, 0))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+
+	// another command
+	_, err = ParseInitIVExpand2(&packets.Command{Name: "initivexpand"}, now)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidCommand))
+}
+
+func TestParseInitIVExpand2Expired(t *testing.T) {
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	lic := license.License{LicenseVersion: 0x01}
+	lic.AddLicenseBlock(license.Block{
+		BlockType:        license.BlockTypeServer,
+		MinimumValidData: license.ValidDataDifference,
+		MaximumValidData: license.ValidDataDifference + 3600,
+		Content:          license.NewServerBlock(7),
+	})
+	lic.AddLicenseBlock(license.Block{
+		BlockType:        license.BlockTypeEphemeral,
+		MinimumValidData: license.ValidDataDifference,
+		MaximumValidData: 4294967295,
+		Content:          license.NewEphemeralBlock(),
+	})
+
+	cmd, err := NewInitIVExpand2(lic, serverKey)
+	assert.NoError(t, err)
+	_, err = ParseInitIVExpand2(cmd, time.Unix(license.ValidDataDifference+60, 0))
+	assert.NoError(t, err)
+	_, err = ParseInitIVExpand2(cmd, time.Unix(license.ValidDataDifference+7200, 0))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+
+	// the chain has to end with the ephemeral block
+	lic.Blocks = lic.Blocks[:1]
+	cmd, err = NewInitIVExpand2(lic, serverKey)
+	assert.NoError(t, err)
+	_, err = ParseInitIVExpand2(cmd, time.Unix(license.ValidDataDifference+60, 0))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+
+	// a block key which is not a point is rejected, the proof is made over the
+	// tampered license by the server
+	lic = license.NewDefaultLicense()
+	lic.Blocks[1].PublicKey = make([]byte, 32)
+	lic.Blocks[1].PublicKey[0] = 0x02
+	cmd, err = NewInitIVExpand2(lic, serverKey)
+	assert.NoError(t, err)
+	_, err = ParseInitIVExpand2(cmd, time.Now())
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+}
+
+func TestNewInitIVExpand(t *testing.T) {
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serverKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	alpha := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.True(t, SupportsInitIVExpand2(&packets.Command{Name: "clientinitiv", Params: map[string]string{"ot": "1"}}))
+	assert.False(t, SupportsInitIVExpand2(&packets.Command{Name: "clientinitiv", Params: map[string]string{}}))
+
+	cmd, err := NewInitIVExpand(alpha, serverKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "initivexpand", cmd.Name)
+	echoed, _ := base64.StdEncoding.DecodeString(cmd.Params["alpha"])
+	assert.Equal(t, alpha, echoed)
+	beta, _ := base64.StdEncoding.DecodeString(cmd.Params["beta"])
+	assert.Len(t, beta, 10)
+
+	// the client reads the server key from omega and derives the same secret
+	omegaRaw, _ := base64.StdEncoding.DecodeString(cmd.Params["omega"])
+	omega := &ts3Crypto.ASN1Omega{}
+	assert.NoError(t, omega.Decode(omegaRaw))
+	serverPublicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: omega.PublicKeyX, Y: omega.PublicKeyY}
+	clientIV, _, err := ts3Crypto.LegacySharedSecret(clientKey, serverPublicKey, alpha, beta)
+	assert.NoError(t, err)
+	serverIV, _, err := ts3Crypto.LegacySharedSecret(serverKey, &clientKey.PublicKey, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, serverIV, clientIV)
+}
+
+func TestParseInitIVExpand2ServerLicense(t *testing.T) {
+	raw, err := base64.StdEncoding.DecodeString(licensetest.ServerLicense)
+	assert.NoError(t, err)
+	lic := license.License{}
+	assert.NoError(t, lic.Unmarshal(raw))
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	cmd, err := NewInitIVExpand2(lic, serverKey)
+	assert.NoError(t, err)
+
+	parsed, err := ParseInitIVExpand2(cmd, licensetest.ServerLicenseTime)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, parsed.License)
+	assert.Equal(t, licensetest.ServerEK, parsed.ServerEK)
+
+	_, err = ParseInitIVExpand2(cmd, licensetest.ServerLicenseTime.Add(72*time.Hour))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+}
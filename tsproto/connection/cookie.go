@@ -0,0 +1,95 @@
+package connection
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCookieLifetime = time.Minute
+	DefaultCookieRotation = 2 * time.Minute
+
+	cookieSecretSize = 32
+)
+
+// CookieJar issues the stateless cookies of the low-level init. The server puts a
+// cookie into Init1Packet.Random1 instead of keeping state for Init0, and only
+// creates the state of a client when its Init2Packet echoes a valid cookie.
+//
+// A cookie is the 4 bytes issue time followed by the first 12 bytes of
+// HMAC-SHA256(secret, address | issue time | Random0), the secret rotates every
+// Rotation and the previous secret is still accepted.
+type CookieJar struct {
+	Lifetime time.Duration
+	Rotation time.Duration
+
+	mu        sync.Mutex
+	current   []byte
+	previous  []byte
+	rotatedAt time.Time
+}
+
+// NewCookieJar creates a CookieJar with the default lifetime and rotation
+func NewCookieJar() *CookieJar {
+	return &CookieJar{
+		Lifetime: DefaultCookieLifetime,
+		Rotation: DefaultCookieRotation,
+	}
+}
+
+// Issue creates the cookie for Init0 with random0 from the address at now
+func (j *CookieJar) Issue(addr string, random0 [4]byte, now time.Time) [16]byte {
+	j.mu.Lock()
+	secret := j.secret(now)
+	j.mu.Unlock()
+
+	var cookie [16]byte
+	binary.BigEndian.PutUint32(cookie[0:4], uint32(now.Unix()))
+	copy(cookie[4:], cookieMAC(secret, addr, cookie[0:4], random0))
+	return cookie
+}
+
+// Verify checks the cookie echoed by Init2 with random0 from the address at now
+func (j *CookieJar) Verify(addr string, random0 [4]byte, cookie [16]byte, now time.Time) bool {
+	issued := time.Unix(int64(binary.BigEndian.Uint32(cookie[0:4])), 0)
+	// a second of clock granularity
+	if issued.After(now.Add(time.Second)) || now.Sub(issued) > j.Lifetime {
+		return false
+	}
+
+	j.mu.Lock()
+	current := j.secret(now)
+	previous := j.previous
+	j.mu.Unlock()
+
+	for _, secret := range [][]byte{current, previous} {
+		if secret != nil && hmac.Equal(cookie[4:], cookieMAC(secret, addr, cookie[0:4], random0)) {
+			return true
+		}
+	}
+	return false
+}
+
+// secret returns the current secret and rotates it when it is too old,
+// the caller holds mu
+func (j *CookieJar) secret(now time.Time) []byte {
+	if j.current == nil || now.Sub(j.rotatedAt) >= j.Rotation {
+		secret := make([]byte, cookieSecretSize)
+		_, _ = rand.Read(secret)
+		j.previous, j.current = j.current, secret
+		j.rotatedAt = now
+	}
+	return j.current
+}
+
+func cookieMAC(secret []byte, addr string, issued []byte, random0 [4]byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(addr))
+	mac.Write(issued)
+	mac.Write(random0[:])
+	return mac.Sum(nil)[:12]
+}
@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieJar(t *testing.T) {
+	j := NewCookieJar()
+	now := time.Unix(1700000000, 0)
+	random0 := [4]byte{1, 2, 3, 4}
+
+	cookie := j.Issue("127.0.0.1:5000", random0, now)
+	assert.True(t, j.Verify("127.0.0.1:5000", random0, cookie, now.Add(time.Second)))
+
+	// bound to the address, Random0 and the issue time
+	assert.False(t, j.Verify("127.0.0.2:5000", random0, cookie, now))
+	assert.False(t, j.Verify("127.0.0.1:5000", [4]byte{1, 2, 3, 5}, cookie, now))
+	tampered := cookie
+	tampered[3]++
+	assert.False(t, j.Verify("127.0.0.1:5000", random0, tampered, now))
+	tampered = cookie
+	tampered[15] ^= 1
+	assert.False(t, j.Verify("127.0.0.1:5000", random0, tampered, now))
+
+	// expired
+	assert.False(t, j.Verify("127.0.0.1:5000", random0, cookie, now.Add(DefaultCookieLifetime+time.Second)))
+	// issued in the future
+	assert.False(t, j.Verify("127.0.0.1:5000", random0, cookie, now.Add(-time.Minute)))
+}
+
+func TestCookieJarRotation(t *testing.T) {
+	j := NewCookieJar()
+	j.Lifetime = time.Hour
+	now := time.Unix(1700000000, 0)
+	random0 := [4]byte{1, 2, 3, 4}
+
+	cookie := j.Issue("127.0.0.1:5000", random0, now)
+	// the previous secret is still accepted after one rotation
+	now = now.Add(DefaultCookieRotation)
+	assert.True(t, j.Verify("127.0.0.1:5000", random0, cookie, now))
+	fresh := j.Issue("127.0.0.1:5000", random0, now)
+	assert.True(t, j.Verify("127.0.0.1:5000", random0, fresh, now))
+
+	// but not after two
+	now = now.Add(DefaultCookieRotation)
+	assert.False(t, j.Verify("127.0.0.1:5000", random0, cookie, now))
+	assert.True(t, j.Verify("127.0.0.1:5000", random0, fresh, now))
+}
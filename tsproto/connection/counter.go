@@ -0,0 +1,112 @@
+package connection
+
+import (
+	"sync"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+// packetTypeCount is the count of packet types with their own id space
+const packetTypeCount = int(packets.PacketTypeInit1) + 1
+
+// checkPacketType rejects the types without id space, the type field of a
+// header is 4 bits wide so a peer can send types up to 15
+func checkPacketType(t packets.PacketType) error {
+	if t < 0 || int(t) >= packetTypeCount {
+		return tsErrors.Errorf(tsErrors.UnknownPacketType, t)
+	}
+	return nil
+}
+
+// PacketCounter hands out the ids of outgoing packets per packet type, the id wraps
+// around after 65535 and bumps the generation of the packet type
+type PacketCounter struct {
+	mu          sync.Mutex
+	ids         [packetTypeCount]uint16
+	generations [packetTypeCount]uint32
+}
+
+// Next returns the id and generation for the next outgoing packet of the type
+func (c *PacketCounter) Next(t packets.PacketType) (uint16, uint32, error) {
+	if err := checkPacketType(t); err != nil {
+		return 0, 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, generation := c.ids[t], c.generations[t]
+	c.ids[t]++
+	if c.ids[t] == 0 {
+		c.generations[t]++
+	}
+	return id, generation, nil
+}
+
+// Current returns the id and generation the next outgoing packet of the type will use
+func (c *PacketCounter) Current(t packets.PacketType) (uint16, uint32, error) {
+	if err := checkPacketType(t); err != nil {
+		return 0, 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ids[t], c.generations[t], nil
+}
+
+// Set overrides the id and generation the next outgoing packet of the type will use,
+// e.g. the client starts its Command ids from 1 since clientinitiv takes the 0
+func (c *PacketCounter) Set(t packets.PacketType, id uint16, generation uint32) error {
+	if err := checkPacketType(t); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[t], c.generations[t] = id, generation
+	return nil
+}
+
+// GenerationWindow infers the generation of incoming packet ids per packet type.
+// An id is mapped to the generation which places it nearest to the highest accepted
+// id of the type, so the window spans 32768 ids on both sides of it.
+type GenerationWindow struct {
+	mu      sync.Mutex
+	highest [packetTypeCount]uint64
+}
+
+// Infer returns the generation of an incoming packet id without recording it,
+// the packet should be accepted after it has been authenticated
+func (w *GenerationWindow) Infer(t packets.PacketType, id uint16) (uint32, error) {
+	if err := checkPacketType(t); err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return uint32(w.absolute(t, id) >> 16), nil
+}
+
+// Accept records an authenticated incoming packet id and moves the window forward
+// when it is the highest one, it returns the generation of the id
+func (w *GenerationWindow) Accept(t packets.PacketType, id uint16) (uint32, error) {
+	if err := checkPacketType(t); err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	abs := w.absolute(t, id)
+	if abs > w.highest[t] {
+		w.highest[t] = abs
+	}
+	return uint32(abs >> 16), nil
+}
+
+// absolute converts the id into a sequence number counting across generations
+func (w *GenerationWindow) absolute(t packets.PacketType, id uint16) uint64 {
+	highest := w.highest[t]
+	diff := int64(int16(id - uint16(highest)))
+	if diff < 0 && uint64(-diff) > highest {
+		// before the very first packet, stay in generation 0
+		return uint64(id)
+	}
+	return uint64(int64(highest) + diff)
+}
@@ -0,0 +1,109 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+func TestPacketCounter(t *testing.T) {
+	c := &PacketCounter{}
+	next := func(pt packets.PacketType) (uint16, uint32) {
+		id, gen, err := c.Next(pt)
+		assert.NoError(t, err)
+		return id, gen
+	}
+
+	id, gen := next(packets.PacketTypeCommand)
+	assert.Equal(t, uint16(0), id)
+	assert.Equal(t, uint32(0), gen)
+	id, _ = next(packets.PacketTypeCommand)
+	assert.Equal(t, uint16(1), id)
+
+	// every packet type counts on its own
+	id, _ = next(packets.PacketTypeCommandLow)
+	assert.Equal(t, uint16(0), id)
+
+	assert.NoError(t, c.Set(packets.PacketTypeCommand, 65535, 0))
+	id, gen = next(packets.PacketTypeCommand)
+	assert.Equal(t, uint16(65535), id)
+	assert.Equal(t, uint32(0), gen)
+	id, gen = next(packets.PacketTypeCommand)
+	assert.Equal(t, uint16(0), id)
+	assert.Equal(t, uint32(1), gen)
+
+	id, gen, err := c.Current(packets.PacketTypeCommand)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1), id)
+	assert.Equal(t, uint32(1), gen)
+}
+
+func TestGenerationWindow(t *testing.T) {
+	w := &GenerationWindow{}
+	pt := packets.PacketTypeCommand
+	infer := func(pt packets.PacketType, id uint16) uint32 {
+		gen, err := w.Infer(pt, id)
+		assert.NoError(t, err)
+		return gen
+	}
+	accept := func(pt packets.PacketType, id uint16) uint32 {
+		gen, err := w.Accept(pt, id)
+		assert.NoError(t, err)
+		return gen
+	}
+
+	assert.Equal(t, uint32(0), accept(pt, 0))
+	assert.Equal(t, uint32(0), accept(pt, 40000))
+	assert.Equal(t, uint32(0), accept(pt, 65530))
+
+	// wrapped ids belong to the next generation
+	assert.Equal(t, uint32(1), infer(pt, 3))
+	assert.Equal(t, uint32(1), accept(pt, 3))
+	// late packets of the previous generation
+	assert.Equal(t, uint32(0), infer(pt, 65534))
+	assert.Equal(t, uint32(0), accept(pt, 65534))
+	assert.Equal(t, uint32(1), infer(pt, 20000))
+
+	// ids nearer to the end of the previous generation
+	assert.Equal(t, uint32(0), infer(pt, 50000))
+
+	// Infer does not move the window
+	assert.Equal(t, uint32(1), infer(pt, 30000))
+	assert.Equal(t, uint32(0), infer(pt, 40000))
+	assert.Equal(t, uint32(1), accept(pt, 30000))
+	assert.Equal(t, uint32(1), infer(pt, 40000))
+
+	// other packet types are untouched
+	assert.Equal(t, uint32(0), infer(packets.PacketTypeCommandLow, 3))
+}
+
+func TestGenerationWindowLongRun(t *testing.T) {
+	c := &PacketCounter{}
+	w := &GenerationWindow{}
+	for i := 0; i < 3*65536+10; i++ {
+		id, gen, _ := c.Next(packets.PacketTypeVoice)
+		accepted, _ := w.Accept(packets.PacketTypeVoice, id)
+		assert.Equal(t, gen, accepted)
+	}
+}
+
+func TestCounterPacketTypeBounds(t *testing.T) {
+	c := &PacketCounter{}
+	w := &GenerationWindow{}
+	// the type of a header is 4 bits wide
+	for pt := packets.PacketType(packetTypeCount); pt < 16; pt++ {
+		_, _, err := c.Next(pt)
+		assert.True(t, errors.Is(err, tsErrors.ErrUnknownPacketType))
+		_, _, err = c.Current(pt)
+		assert.True(t, errors.Is(err, tsErrors.ErrUnknownPacketType))
+		assert.True(t, errors.Is(c.Set(pt, 1, 0), tsErrors.ErrUnknownPacketType))
+		_, err = w.Infer(pt, 1)
+		assert.True(t, errors.Is(err, tsErrors.ErrUnknownPacketType))
+		_, err = w.Accept(pt, 1)
+		assert.True(t, errors.Is(err, tsErrors.ErrUnknownPacketType))
+	}
+}
@@ -0,0 +1,187 @@
+// Package connection contains the per-connection state machines of the protocol,
+// they are independent of the socket and are driven by the caller.
+package connection
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPingInterval = time.Second
+	DefaultMaxMissed    = 30
+)
+
+// KeepaliveSender sends the keepalive packets of a connection
+type KeepaliveSender interface {
+	// SendPing sends a ping and returns its packet id
+	SendPing() (uint16, error)
+	// SendPong answers the ping with the packet id
+	SendPong(pingId uint16) error
+}
+
+// Keepalive sends pings on an interval, answers the pings of the peer and feeds
+// the round-trip time of the pongs into RTT. The peer is declared dead after
+// MaxMissed pings without pong, a ping which could not be sent counts as missed.
+type Keepalive struct {
+	// Interval and MaxMissed fall back to the defaults when not positive
+	Interval  time.Duration
+	MaxMissed int
+	Sender    KeepaliveSender
+	// RTT receives the round-trip samples, it is shared with the SendQueue of
+	// the connection
+	RTT *RTTEstimator
+	// OnDead is called once when the peer is declared dead
+	OnDead func()
+
+	mu      sync.Mutex
+	pending map[uint16]time.Time
+	missed  int
+	failed  bool
+	dead    bool
+	stop    chan struct{}
+}
+
+// NewKeepalive creates a Keepalive with the default interval and threshold
+func NewKeepalive(sender KeepaliveSender, onDead func()) *Keepalive {
+	return &Keepalive{
+		Interval:  DefaultPingInterval,
+		MaxMissed: DefaultMaxMissed,
+		Sender:    sender,
+		RTT:       NewRTTEstimator(),
+		OnDead:    onDead,
+	}
+}
+
+// Start runs Tick on the interval in a new goroutine until Stop is called
+// or the peer is dead, send errors do not stop it
+func (k *Keepalive) Start() {
+	k.mu.Lock()
+	if k.stop != nil {
+		k.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	k.stop = stop
+	k.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(k.interval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				// the error is counted as a missed ping by Tick
+				_ = k.Tick(now)
+				if k.Dead() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the goroutine started by Start
+func (k *Keepalive) Stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stop != nil {
+		close(k.stop)
+		k.stop = nil
+	}
+}
+
+// Tick counts the pings not answered yet as missed and sends a new ping
+func (k *Keepalive) Tick(now time.Time) error {
+	k.mu.Lock()
+	if k.dead {
+		k.mu.Unlock()
+		return nil
+	}
+	if len(k.pending) > 0 || k.failed {
+		k.missed++
+	}
+	k.failed = false
+	if k.missed >= k.maxMissed() {
+		k.dead = true
+		k.pending = nil
+		k.mu.Unlock()
+		if k.OnDead != nil {
+			k.OnDead()
+		}
+		return nil
+	}
+	k.mu.Unlock()
+
+	pingId, err := k.Sender.SendPing()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		k.failed = true
+		return err
+	}
+	if k.pending == nil {
+		k.pending = make(map[uint16]time.Time)
+	}
+	k.pending[pingId] = now
+	// only keep the pings which may still be answered in time
+	for id, sent := range k.pending {
+		if now.Sub(sent) > time.Duration(k.maxMissed())*k.interval() {
+			delete(k.pending, id)
+		}
+	}
+	return nil
+}
+
+func (k *Keepalive) interval() time.Duration {
+	if k.Interval <= 0 {
+		return DefaultPingInterval
+	}
+	return k.Interval
+}
+
+func (k *Keepalive) maxMissed() int {
+	if k.MaxMissed <= 0 {
+		return DefaultMaxMissed
+	}
+	return k.MaxMissed
+}
+
+// HandlePing answers a ping of the peer
+func (k *Keepalive) HandlePing(pingId uint16) error {
+	return k.Sender.SendPong(pingId)
+}
+
+// HandlePong resets the missed pings and samples the round-trip time,
+// it returns false when the pong does not match any ping sent
+func (k *Keepalive) HandlePong(pingId uint16, now time.Time) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	sent, ok := k.pending[pingId]
+	if !ok {
+		return false
+	}
+	// older pings are answered implicitly
+	for id, t := range k.pending {
+		if !t.After(sent) {
+			delete(k.pending, id)
+		}
+	}
+
+	k.missed = 0
+	if k.RTT != nil {
+		k.RTT.Sample(now.Sub(sent))
+	}
+	return true
+}
+
+// Dead reports whether the peer is declared dead
+func (k *Keepalive) Dead() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.dead
+}
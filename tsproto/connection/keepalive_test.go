@@ -0,0 +1,138 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSender struct {
+	pingId uint16
+	pongs  []uint16
+	err    error
+}
+
+func (f *fakeSender) SendPing() (uint16, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.pingId++
+	return f.pingId, nil
+}
+
+func (f *fakeSender) SendPong(pingId uint16) error {
+	f.pongs = append(f.pongs, pingId)
+	return nil
+}
+
+func TestKeepaliveRTT(t *testing.T) {
+	sender := &fakeSender{}
+	k := NewKeepalive(sender, nil)
+	now := time.Unix(0, 0)
+
+	// the pongs feed the estimator shared with the SendQueue
+	q := NewSendQueue(&fakeRawSender{}, nil)
+	k.RTT = q.RTT
+
+	assert.NoError(t, k.Tick(now))
+	assert.True(t, k.HandlePong(sender.pingId, now.Add(40*time.Millisecond)))
+	smoothed, _ := q.RTT.Smoothed()
+	assert.Equal(t, 40*time.Millisecond, smoothed)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, k.Tick(now))
+	assert.True(t, k.HandlePong(sender.pingId, now.Add(120*time.Millisecond)))
+	smoothed, _ = q.RTT.Smoothed()
+	assert.Equal(t, 50*time.Millisecond, smoothed)
+	assert.Equal(t, 190*time.Millisecond, q.RTT.RTO())
+
+	// unknown and duplicated pongs are ignored
+	assert.False(t, k.HandlePong(sender.pingId, now.Add(time.Second)))
+	assert.False(t, k.HandlePong(1000, now))
+
+	assert.NoError(t, k.HandlePing(7))
+	assert.Equal(t, []uint16{7}, sender.pongs)
+}
+
+func TestKeepaliveDead(t *testing.T) {
+	sender := &fakeSender{}
+	deadCount := 0
+	k := NewKeepalive(sender, func() { deadCount++ })
+	k.MaxMissed = 3
+	now := time.Unix(0, 0)
+
+	// a late pong resets the missed pings
+	assert.NoError(t, k.Tick(now))
+	assert.NoError(t, k.Tick(now.Add(time.Second)))
+	assert.True(t, k.HandlePong(1, now.Add(1500*time.Millisecond)))
+
+	for i := 2; i < 6; i++ {
+		assert.NoError(t, k.Tick(now.Add(time.Duration(i)*time.Second)))
+	}
+	assert.True(t, k.Dead())
+	assert.Equal(t, 1, deadCount)
+
+	// nothing is sent after dead
+	pingId := sender.pingId
+	assert.NoError(t, k.Tick(now.Add(10*time.Second)))
+	assert.Equal(t, pingId, sender.pingId)
+	assert.Equal(t, 1, deadCount)
+}
+
+func TestKeepaliveStart(t *testing.T) {
+	sender := &fakeSender{}
+	dead := make(chan struct{})
+	k := NewKeepalive(sender, func() { close(dead) })
+	k.Interval = time.Millisecond
+	k.MaxMissed = 2
+	k.Start()
+	defer k.Stop()
+
+	select {
+	case <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("peer is not declared dead")
+	}
+}
+
+func TestKeepaliveSendError(t *testing.T) {
+	sender := &fakeSender{err: errors.New("network is unreachable")}
+	deadCount := 0
+	k := NewKeepalive(sender, func() { deadCount++ })
+	k.MaxMissed = 3
+	now := time.Unix(0, 0)
+
+	// pings which could not be sent are missed as well
+	for i := 0; i < 3; i++ {
+		assert.Error(t, k.Tick(now.Add(time.Duration(i)*time.Second)))
+	}
+	assert.False(t, k.Dead())
+	assert.NoError(t, k.Tick(now.Add(3*time.Second)))
+	assert.True(t, k.Dead())
+	assert.Equal(t, 1, deadCount)
+
+	// the goroutine keeps ticking on send errors until the peer is dead
+	dead := make(chan struct{})
+	k = NewKeepalive(sender, func() { close(dead) })
+	k.Interval = time.Millisecond
+	k.MaxMissed = 2
+	k.Start()
+	defer k.Stop()
+
+	select {
+	case <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("peer is not declared dead")
+	}
+}
+
+func TestKeepaliveZeroValue(t *testing.T) {
+	k := &Keepalive{Sender: &fakeSender{}}
+	assert.Equal(t, DefaultPingInterval, k.interval())
+	assert.Equal(t, DefaultMaxMissed, k.maxMissed())
+
+	k.Start()
+	k.Stop()
+}
@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"sync"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+const DefaultReceiveWindowSize = 1024
+
+// AckSender acknowledges received Command and CommandLow packets
+type AckSender interface {
+	// SendAck acknowledges the packet of the type with the packet id
+	SendAck(t packets.PacketType, packetId uint16) error
+}
+
+// ReceiveWindow orders the incoming packets of one command channel (Command or
+// CommandLow). Every packet inside the window is acked, duplicates are dropped and
+// the packets ahead of the next expected one are buffered until the gap is filled.
+// Packets are tracked by their generation and id, so duplicates are recognized
+// across a generation wrap as well.
+type ReceiveWindow struct {
+	// Size is the count of packet ids accepted ahead of the next expected one
+	Size   int
+	Type   packets.PacketType
+	Sender AckSender
+
+	mu       sync.Mutex
+	next     uint64
+	buffered map[uint64]*packets.CommandPacket
+}
+
+// NewReceiveWindow creates a ReceiveWindow with the default size for the packet type
+func NewReceiveWindow(t packets.PacketType, sender AckSender) *ReceiveWindow {
+	return &ReceiveWindow{
+		Size:   DefaultReceiveWindowSize,
+		Type:   t,
+		Sender: sender,
+	}
+}
+
+// Expect sets the id and generation of the next expected packet and drops the
+// buffered packets, e.g. the server expects the Command ids from 1 since
+// clientinitiv takes the 0
+func (w *ReceiveWindow) Expect(id uint16, generation uint32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.next = uint64(generation)<<16 | uint64(id)
+	w.buffered = nil
+}
+
+// Push acks the authenticated packet and returns the packets which are now ready
+// in packet id order. The generation of the packet is the one inferred for its
+// decryption, see GenerationWindow.
+func (w *ReceiveWindow) Push(cp *packets.CommandPacket, generation uint32) ([]*packets.CommandPacket, error) {
+	var id uint16
+	if cp.C2S != nil {
+		id = cp.C2S.PacketId
+	} else if cp.S2C != nil {
+		id = cp.S2C.PacketId
+	}
+	seq := uint64(generation)<<16 | uint64(id)
+
+	w.mu.Lock()
+	// packets beyond the window are dropped without ack, the peer resends them
+	if seq >= w.next+uint64(w.Size) {
+		w.mu.Unlock()
+		return nil, nil
+	}
+
+	var ready []*packets.CommandPacket
+	// the ack of a packet already released may have been lost, ack it again
+	if _, ok := w.buffered[seq]; !ok && seq >= w.next {
+		if w.buffered == nil {
+			w.buffered = make(map[uint64]*packets.CommandPacket)
+		}
+		w.buffered[seq] = cp
+		for {
+			p, ok := w.buffered[w.next]
+			if !ok {
+				break
+			}
+			delete(w.buffered, w.next)
+			ready = append(ready, p)
+			w.next++
+		}
+	}
+	w.mu.Unlock()
+
+	if w.Sender != nil {
+		if err := w.Sender.SendAck(w.Type, id); err != nil {
+			return ready, err
+		}
+	}
+	return ready, nil
+}
+
+// Buffered returns the count of packets waiting for a gap to be filled
+func (w *ReceiveWindow) Buffered() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.buffered)
+}
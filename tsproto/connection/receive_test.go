@@ -0,0 +1,100 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+type fakeAckSender struct {
+	acks []uint16
+}
+
+func (f *fakeAckSender) SendAck(t packets.PacketType, packetId uint16) error {
+	f.acks = append(f.acks, packetId)
+	return nil
+}
+
+func commandPacket(id uint16) *packets.CommandPacket {
+	return &packets.CommandPacket{
+		C2S: &packets.C2SPacket{
+			PacketId:   id,
+			PacketType: packets.PacketTypeCommand,
+		},
+	}
+}
+
+func packetIds(ps []*packets.CommandPacket) []uint16 {
+	ids := make([]uint16, 0, len(ps))
+	for _, p := range ps {
+		ids = append(ids, p.C2S.PacketId)
+	}
+	return ids
+}
+
+func TestReceiveWindowOrder(t *testing.T) {
+	sender := &fakeAckSender{}
+	w := NewReceiveWindow(packets.PacketTypeCommand, sender)
+	w.Expect(1, 0)
+
+	ready, err := w.Push(commandPacket(1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{1}, packetIds(ready))
+
+	// out of order packets wait for the gap
+	ready, _ = w.Push(commandPacket(3), 0)
+	assert.Empty(t, ready)
+	ready, _ = w.Push(commandPacket(4), 0)
+	assert.Empty(t, ready)
+	assert.Equal(t, 2, w.Buffered())
+	ready, _ = w.Push(commandPacket(2), 0)
+	assert.Equal(t, []uint16{2, 3, 4}, packetIds(ready))
+	assert.Equal(t, 0, w.Buffered())
+
+	// duplicates are acked again but not released
+	ready, _ = w.Push(commandPacket(3), 0)
+	assert.Empty(t, ready)
+	ready, _ = w.Push(commandPacket(6), 0)
+	assert.Empty(t, ready)
+	ready, _ = w.Push(commandPacket(6), 0)
+	assert.Empty(t, ready)
+	assert.Equal(t, 1, w.Buffered())
+
+	assert.Equal(t, []uint16{1, 3, 4, 2, 3, 6, 6}, sender.acks)
+}
+
+func TestReceiveWindowBounds(t *testing.T) {
+	sender := &fakeAckSender{}
+	w := NewReceiveWindow(packets.PacketTypeCommand, sender)
+	w.Size = 4
+
+	// beyond the window, dropped without ack
+	ready, _ := w.Push(commandPacket(4), 0)
+	assert.Empty(t, ready)
+	assert.Empty(t, sender.acks)
+	ready, _ = w.Push(commandPacket(3), 0)
+	assert.Empty(t, ready)
+	assert.Equal(t, []uint16{3}, sender.acks)
+}
+
+func TestReceiveWindowGenerationWrap(t *testing.T) {
+	sender := &fakeAckSender{}
+	w := NewReceiveWindow(packets.PacketTypeCommand, sender)
+	w.Expect(65534, 0)
+
+	ready, _ := w.Push(commandPacket(0), 1)
+	assert.Empty(t, ready)
+	ready, _ = w.Push(commandPacket(65535), 0)
+	assert.Empty(t, ready)
+	ready, _ = w.Push(commandPacket(65534), 0)
+	assert.Equal(t, []uint16{65534, 65535, 0}, packetIds(ready))
+
+	// the same id of the previous generation is a duplicate
+	ready, _ = w.Push(commandPacket(65535), 0)
+	assert.Empty(t, ready)
+	// the same id of the next generation is not
+	ready, _ = w.Push(commandPacket(1), 1)
+	assert.Equal(t, []uint16{1}, packetIds(ready))
+}
@@ -0,0 +1,205 @@
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+const (
+	DefaultResendInterval = 100 * time.Millisecond
+	DefaultGiveUpAfter    = 30 * time.Second
+)
+
+// RawSender sends an already marshaled and encrypted packet
+type RawSender interface {
+	SendRaw(raw []byte) error
+}
+
+type pendingKey struct {
+	t  packets.PacketType
+	id uint16
+}
+
+type pendingPacket struct {
+	raw      []byte
+	firstAt  time.Time
+	lastAt   time.Time
+	deadline time.Time
+	retries  int
+}
+
+// SendQueue keeps the sent Command and CommandLow packets until they are acked and
+// retransmits them when the timeout given by the RTTEstimator expires. When a packet
+// is still not acked after GiveUpAfter the connection is given up.
+type SendQueue struct {
+	// Interval is the interval of Tick when started with Start
+	Interval    time.Duration
+	GiveUpAfter time.Duration
+	Sender      RawSender
+	RTT         *RTTEstimator
+	// OnGiveUp is called once when a packet is not acked in time
+	OnGiveUp func()
+
+	mu      sync.Mutex
+	pending map[pendingKey]*pendingPacket
+	dead    bool
+	stop    chan struct{}
+}
+
+// NewSendQueue creates a SendQueue with the default interval and threshold
+func NewSendQueue(sender RawSender, onGiveUp func()) *SendQueue {
+	return &SendQueue{
+		Interval:    DefaultResendInterval,
+		GiveUpAfter: DefaultGiveUpAfter,
+		Sender:      sender,
+		RTT:         NewRTTEstimator(),
+		OnGiveUp:    onGiveUp,
+	}
+}
+
+// Send sends the raw packet and keeps it until Ack is called with its type and id,
+// only Command and CommandLow packets are kept, other types are only sent
+func (q *SendQueue) Send(t packets.PacketType, packetId uint16, raw []byte, now time.Time) error {
+	if _, ok := packets.AckTypeFor(t); ok {
+		q.mu.Lock()
+		if q.dead {
+			q.mu.Unlock()
+			return nil
+		}
+		if q.pending == nil {
+			q.pending = make(map[pendingKey]*pendingPacket)
+		}
+		q.pending[pendingKey{t, packetId}] = &pendingPacket{
+			raw:      raw,
+			firstAt:  now,
+			lastAt:   now,
+			deadline: now.Add(q.RTT.RTO()),
+		}
+		q.mu.Unlock()
+	}
+	return q.Sender.SendRaw(raw)
+}
+
+// Ack removes the packet acknowledged by an ack of the type, t is the type of the
+// acknowledged packet (see packets.AckPacket.AckedType). It returns false when the
+// packet is not pending, e.g. a duplicated ack.
+func (q *SendQueue) Ack(t packets.PacketType, packetId uint16, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := pendingKey{t, packetId}
+	p, ok := q.pending[key]
+	if !ok {
+		return false
+	}
+	delete(q.pending, key)
+	// the round-trip time of a retransmitted packet is ambiguous (Karn's algorithm)
+	if p.retries == 0 {
+		q.RTT.Sample(now.Sub(p.lastAt))
+	}
+	return true
+}
+
+// Tick retransmits the packets whose timeout expired and gives up the connection
+// when a packet is pending for longer than GiveUpAfter
+func (q *SendQueue) Tick(now time.Time) error {
+	q.mu.Lock()
+	if q.dead {
+		q.mu.Unlock()
+		return nil
+	}
+
+	var resend [][]byte
+	for _, p := range q.pending {
+		if now.Sub(p.firstAt) >= q.GiveUpAfter {
+			q.dead = true
+			q.pending = nil
+			q.mu.Unlock()
+			if q.OnGiveUp != nil {
+				q.OnGiveUp()
+			}
+			return nil
+		}
+		if now.Before(p.deadline) {
+			continue
+		}
+		p.retries++
+		p.lastAt = now
+		p.deadline = now.Add(q.RTT.Backoff(p.retries))
+		resend = append(resend, p.raw)
+	}
+	q.mu.Unlock()
+
+	// a failed packet is retried on its next timeout, the others are still sent
+	var firstErr error
+	for _, raw := range resend {
+		if err := q.Sender.SendRaw(raw); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Pending returns the count of packets not acked yet
+func (q *SendQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Dead reports whether the connection has been given up
+func (q *SendQueue) Dead() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dead
+}
+
+// Start runs Tick on the interval in a new goroutine until Stop is called
+// or the connection is given up, send errors do not stop it
+func (q *SendQueue) Start() {
+	q.mu.Lock()
+	if q.stop != nil {
+		q.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	q.stop = stop
+	q.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(q.interval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				// the packets are kept until acked or given up, so a send
+				// error is only retried on the following ticks
+				_ = q.Tick(now)
+				if q.Dead() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (q *SendQueue) interval() time.Duration {
+	if q.Interval <= 0 {
+		return DefaultResendInterval
+	}
+	return q.Interval
+}
+
+// Stop stops the goroutine started by Start
+func (q *SendQueue) Stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		close(q.stop)
+		q.stop = nil
+	}
+}
@@ -0,0 +1,154 @@
+package connection
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+type fakeRawSender struct {
+	mu   sync.Mutex
+	sent [][]byte
+	// fail is the count of sends still failing
+	fail int
+}
+
+func (f *fakeRawSender) SendRaw(raw []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("network is unreachable")
+	}
+	f.sent = append(f.sent, raw)
+	return nil
+}
+
+func TestRTTEstimator(t *testing.T) {
+	e := NewRTTEstimator()
+	assert.Equal(t, DefaultInitialRTO, e.RTO())
+
+	e.Sample(100 * time.Millisecond)
+	srtt, rttvar := e.Smoothed()
+	assert.Equal(t, 100*time.Millisecond, srtt)
+	assert.Equal(t, 50*time.Millisecond, rttvar)
+	assert.Equal(t, 300*time.Millisecond, e.RTO())
+
+	e.Sample(180 * time.Millisecond)
+	srtt, rttvar = e.Smoothed()
+	assert.Equal(t, 110*time.Millisecond, srtt)
+	assert.Equal(t, 57500*time.Microsecond, rttvar)
+	assert.Equal(t, 340*time.Millisecond, e.RTO())
+
+	assert.Equal(t, 680*time.Millisecond, e.Backoff(1))
+	assert.Equal(t, 1360*time.Millisecond, e.Backoff(2))
+	assert.Equal(t, DefaultMaxRTO, e.Backoff(100))
+
+	// a zero first sample initializes the estimator as well
+	z := NewRTTEstimator()
+	z.Sample(0)
+	z.Sample(80 * time.Millisecond)
+	srtt, rttvar = z.Smoothed()
+	assert.Equal(t, 10*time.Millisecond, srtt)
+	assert.Equal(t, 20*time.Millisecond, rttvar)
+
+	// tiny samples stay above the lower bound
+	for i := 0; i < 50; i++ {
+		e.Sample(time.Millisecond)
+	}
+	assert.Equal(t, DefaultMinRTO, e.RTO())
+}
+
+func TestSendQueueRetransmit(t *testing.T) {
+	sender := &fakeRawSender{}
+	q := NewSendQueue(sender, nil)
+	now := time.Unix(0, 0)
+
+	assert.NoError(t, q.Send(packets.PacketTypeCommand, 1, []byte{1}, now))
+	assert.NoError(t, q.Send(packets.PacketTypeCommandLow, 1, []byte{2}, now))
+	// unreliable packets are not kept
+	assert.NoError(t, q.Send(packets.PacketTypePing, 1, []byte{3}, now))
+	assert.Equal(t, 2, q.Pending())
+	assert.Len(t, sender.sent, 3)
+
+	// nothing to resend before the timeout
+	assert.NoError(t, q.Tick(now.Add(500*time.Millisecond)))
+	assert.Len(t, sender.sent, 3)
+
+	// the Command packet is acked, the CommandLow packet is resent
+	assert.True(t, q.Ack(packets.PacketTypeCommand, 1, now.Add(600*time.Millisecond)))
+	assert.False(t, q.Ack(packets.PacketTypeCommand, 1, now.Add(600*time.Millisecond)))
+	assert.NoError(t, q.Tick(now.Add(DefaultInitialRTO)))
+	assert.Equal(t, [][]byte{{1}, {2}, {3}, {2}}, sender.sent)
+
+	// the sample of the acked packet drives the next timeout
+	srtt, _ := q.RTT.Smoothed()
+	assert.Equal(t, 600*time.Millisecond, srtt)
+
+	// the ack of a retransmitted packet does not sample the round-trip time
+	assert.True(t, q.Ack(packets.PacketTypeCommandLow, 1, now.Add(5*time.Second)))
+	srtt, _ = q.RTT.Smoothed()
+	assert.Equal(t, 600*time.Millisecond, srtt)
+	assert.Equal(t, 0, q.Pending())
+}
+
+func TestSendQueueGiveUp(t *testing.T) {
+	sender := &fakeRawSender{}
+	giveUpCount := 0
+	q := NewSendQueue(sender, func() { giveUpCount++ })
+	q.GiveUpAfter = 5 * time.Second
+	now := time.Unix(0, 0)
+
+	assert.NoError(t, q.Send(packets.PacketTypeCommand, 0, []byte{1}, now))
+	for i := 1; i <= 50; i++ {
+		assert.NoError(t, q.Tick(now.Add(time.Duration(i)*100*time.Millisecond)))
+	}
+	assert.True(t, q.Dead())
+	assert.Equal(t, 1, giveUpCount)
+	assert.Equal(t, 0, q.Pending())
+
+	// the timeout doubles, 1s, 2s
+	assert.Len(t, sender.sent, 3)
+
+	assert.NoError(t, q.Tick(now.Add(time.Minute)))
+	assert.Equal(t, 1, giveUpCount)
+}
+
+func TestSendQueueSendError(t *testing.T) {
+	sender := &fakeRawSender{}
+	q := NewSendQueue(sender, nil)
+	now := time.Unix(0, 0)
+
+	assert.NoError(t, q.Send(packets.PacketTypeCommand, 1, []byte{1}, now))
+	assert.NoError(t, q.Send(packets.PacketTypeCommand, 2, []byte{2}, now))
+
+	// one failed retransmission does not hold back the other one
+	sender.fail = 1
+	assert.Error(t, q.Tick(now.Add(DefaultInitialRTO)))
+	assert.Len(t, sender.sent, 3)
+	assert.Equal(t, 2, q.Pending())
+
+	// the goroutine keeps retransmitting through send errors until it gives up
+	giveUp := make(chan struct{})
+	q = NewSendQueue(sender, func() { close(giveUp) })
+	q.Interval = time.Millisecond
+	q.GiveUpAfter = 50 * time.Millisecond
+	q.RTT.MinRTO = time.Millisecond
+	assert.NoError(t, q.Send(packets.PacketTypeCommand, 1, []byte{1}, time.Now()))
+	sender.mu.Lock()
+	sender.fail = 1000
+	sender.mu.Unlock()
+	q.Start()
+	defer q.Stop()
+
+	select {
+	case <-giveUp:
+	case <-time.After(time.Second):
+		t.Fatal("connection is not given up")
+	}
+}
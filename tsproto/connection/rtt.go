@@ -0,0 +1,96 @@
+package connection
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInitialRTO = time.Second
+	DefaultMinRTO     = 100 * time.Millisecond
+	DefaultMaxRTO     = 10 * time.Second
+)
+
+// RTTEstimator estimates the retransmission timeout from round-trip samples,
+// it keeps the smoothed round-trip time and its variance like TCP does (RFC 6298).
+// The acks of the SendQueue and the pongs of the Keepalive of a connection feed
+// the same estimator.
+type RTTEstimator struct {
+	MinRTO time.Duration
+	MaxRTO time.Duration
+
+	mu          sync.Mutex
+	initialized bool
+	srtt        time.Duration
+	rttvar      time.Duration
+	rto         time.Duration
+}
+
+// NewRTTEstimator creates a RTTEstimator with the default bounds
+func NewRTTEstimator() *RTTEstimator {
+	return &RTTEstimator{
+		MinRTO: DefaultMinRTO,
+		MaxRTO: DefaultMaxRTO,
+		rto:    DefaultInitialRTO,
+	}
+}
+
+// Sample feeds a measured round-trip time into the estimator
+func (e *RTTEstimator) Sample(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if rtt < 0 {
+		return
+	}
+	if !e.initialized {
+		e.initialized = true
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.rto = e.clamp(e.srtt + 4*e.rttvar)
+}
+
+// RTO returns the current retransmission timeout
+func (e *RTTEstimator) RTO() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rto
+}
+
+// Backoff returns the timeout of a packet which has been sent 1+retries times,
+// the timeout doubles with every retry
+func (e *RTTEstimator) Backoff(retries int) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rto := e.rto
+	for i := 0; i < retries && rto < e.MaxRTO; i++ {
+		rto *= 2
+	}
+	return e.clamp(rto)
+}
+
+// Smoothed returns the smoothed round-trip time and its variance
+func (e *RTTEstimator) Smoothed() (srtt time.Duration, rttvar time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.srtt, e.rttvar
+}
+
+func (e *RTTEstimator) clamp(rto time.Duration) time.Duration {
+	if rto < e.MinRTO {
+		return e.MinRTO
+	}
+	if e.MaxRTO > 0 && rto > e.MaxRTO {
+		return e.MaxRTO
+	}
+	return rto
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/asn1"
 	"math/big"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 )
 
 // ASN1Omega is a parameter of clientinitiv and initivexpand2 command
@@ -50,9 +52,15 @@ func (o ASN1Omega) Encode() ([]byte, error) {
 
 func (o *ASN1Omega) Decode(raw []byte) error {
 	var rawValue asn1.RawValue
-	_, err := asn1.Unmarshal(raw, &rawValue)
+	rest, err := asn1.Unmarshal(raw, &rawValue)
 	if err != nil {
-		return err
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, err.Error())
+	}
+	if len(rest) != 0 {
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, "trailing data")
+	}
+	if rawValue.Class != asn1.ClassUniversal || rawValue.Tag != asn1.TagSequence || !rawValue.IsCompound {
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, "not a sequence")
 	}
 
 	var (
@@ -63,19 +71,25 @@ func (o *ASN1Omega) Decode(raw []byte) error {
 	)
 	omega, err := asn1.Unmarshal(rawValue.Bytes, &bs)
 	if err != nil {
-		return err
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, err.Error())
 	}
 	omega, err = asn1.Unmarshal(omega, &keySize)
 	if err != nil {
-		return err
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, err.Error())
 	}
 	omega, err = asn1.Unmarshal(omega, &publicKeyX)
 	if err != nil {
-		return err
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, err.Error())
 	}
 	omega, err = asn1.Unmarshal(omega, &publicKeyY)
 	if err != nil {
-		return err
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, err.Error())
+	}
+	if len(omega) != 0 {
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, "trailing data in sequence")
+	}
+	if publicKeyX.Sign() < 0 || publicKeyY.Sign() < 0 {
+		return tsErrors.Errorf(tsErrors.InvalidASN1Omega, "negative public key coordinate")
 	}
 
 	o.BS = string(bs.Bytes)
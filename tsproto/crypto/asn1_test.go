@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func TestASN1OmegaRoundTrip(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	o := ASN1Omega{BS: "\x00", KeySize: 32, PublicKeyX: key.X, PublicKeyY: key.Y}
+	raw, err := o.Encode()
+	assert.NoError(t, err)
+
+	decoded := ASN1Omega{}
+	assert.NoError(t, decoded.Decode(raw))
+	assert.Equal(t, o.BS, decoded.BS)
+	assert.Equal(t, o.KeySize, decoded.KeySize)
+	assert.Equal(t, 0, o.PublicKeyX.Cmp(decoded.PublicKeyX))
+	assert.Equal(t, 0, o.PublicKeyY.Cmp(decoded.PublicKeyY))
+
+	err = decoded.Decode(raw[:len(raw)-1])
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidASN1Omega))
+	err = decoded.Decode(append(raw, 0x00))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidASN1Omega))
+}
+
+func FuzzASN1OmegaDecode(f *testing.F) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	raw, _ := ASN1Omega{BS: "\x00", KeySize: 32, PublicKeyX: key.X, PublicKeyY: key.Y}.Encode()
+	f.Add(raw)
+	f.Add([]byte{0x30, 0x00})
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		o := ASN1Omega{}
+		if err := o.Decode(raw); err != nil {
+			return
+		}
+		if _, err := o.Encode(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
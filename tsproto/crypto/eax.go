@@ -1,9 +1,75 @@
 package crypto
 
-func test() {
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
 
+	"github.com/ProtonMail/go-crypto/eax"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+const (
+	eaxNonceSize = 16
+	eaxTagSize   = 8
+
+	flagUnencrypted = 0x80
+)
+
+// packetHeader reads the type and packet id of a packet and returns its header size
+func packetHeader(raw []byte, direction packets.PacketDirection) (packets.PacketType, uint16, int, error) {
+	headerSize := packets.C2SHeaderSize
+	if direction == packets.PacketDirectionS2C {
+		headerSize = packets.S2CHeaderSize
+	}
+	if len(raw) < headerSize {
+		return 0, 0, 0, tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), fmt.Sprintf(">= %d", headerSize))
+	}
+	return packets.PacketType(raw[headerSize-1] & 0x0f), binary.BigEndian.Uint16(raw[8:10]), headerSize, nil
 }
 
-func Encrypt() {
+// seal encrypts the payload after the header with AES-EAX, the header without
+// the MAC is the associated data and the 8 bytes tag becomes the MAC
+func seal(raw []byte, headerSize int, key, nonce []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := eax.NewEAXWithNonceAndTagSize(block, eaxNonceSize, eaxTagSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := aead.Seal(nil, nonce, raw[headerSize:], raw[8:headerSize])
+	payloadSize := len(raw) - headerSize
+	packet := make([]byte, 0, len(raw))
+	packet = append(packet, ret[payloadSize:]...)
+	packet = append(packet, raw[8:headerSize]...)
+	return append(packet, ret[:payloadSize]...), nil
+}
+
+// open decrypts the payload after the header with AES-EAX and checks the MAC
+func open(raw []byte, headerSize int, key, nonce []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := eax.NewEAXWithNonceAndTagSize(block, eaxNonceSize, eaxTagSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, 0, len(raw)-8)
+	ciphertext = append(ciphertext, raw[headerSize:]...)
+	ciphertext = append(ciphertext, raw[:8]...)
+	ret, err := aead.Open(nil, nonce, ciphertext, raw[8:headerSize])
+	if err != nil {
+		return nil, tsErrors.Errorf(tsErrors.DecryptFailed, raw[headerSize-1]&0x0f, binary.BigEndian.Uint16(raw[8:10]))
+	}
 
+	packet := make([]byte, 0, len(raw))
+	packet = append(packet, raw[:headerSize]...)
+	return append(packet, ret...), nil
 }
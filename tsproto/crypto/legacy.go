@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha1"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+// NewLegacySession derives the session secrets of the legacy initivexpand handshake
+// used by clients before 3.1, from the own P-256 omega key, the omega key of the
+// peer and the alpha and beta of the handshake
+func NewLegacySession(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey, alpha, beta []byte) (*Session, error) {
+	sharedIV, sharedMAC, err := LegacySharedSecret(privateKey, publicKey, alpha, beta)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{SharedIV: sharedIV, SharedMAC: sharedMAC}, nil
+}
+
+// LegacySharedSecret computes the 20 bytes SharedIV and the SharedMAC from the
+// P-256 key exchange, SharedIV = (alpha || beta) ^ sha1(x of privateKey * publicKey)
+// and SharedMAC = sha1(SharedIV)[0:8]
+func LegacySharedSecret(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey, alpha, beta []byte) ([]byte, []byte, error) {
+	if len(alpha) != 10 || len(beta) != 10 {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidCommand, "alpha and beta must be 10 bytes")
+	}
+	curve := privateKey.Curve
+	if publicKey.X == nil || publicKey.Y == nil || !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidASN1Omega, "public key not on curve")
+	}
+
+	x, _ := curve.ScalarMult(publicKey.X, publicKey.Y, privateKey.D.Bytes())
+	sharedData := make([]byte, 32)
+	x.FillBytes(sharedData)
+	sharedKey := sha1.Sum(sharedData)
+
+	sharedIV := make([]byte, 20)
+	copy(sharedIV[0:10], alpha)
+	copy(sharedIV[10:20], beta)
+	for i := range sharedIV {
+		sharedIV[i] ^= sharedKey[i]
+	}
+
+	macHash := sha1.Sum(sharedIV)
+	return sharedIV, macHash[:8], nil
+}
@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testP256Key(d string) *ecdsa.PrivateKey {
+	k := &ecdsa.PrivateKey{D: new(big.Int)}
+	k.D.SetString(d, 16)
+	k.Curve = elliptic.P256()
+	k.X, k.Y = k.Curve.ScalarBaseMult(k.D.Bytes())
+	return k
+}
+
+func TestLegacySharedSecret(t *testing.T) {
+	own := testP256Key("1f2e3d4c5b6a79880123456789abcdeffedcba98765432100f1e2d3c4b5a6978")
+	peer := testP256Key("0a1b2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccddeeff0")
+	alpha := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	beta := []byte{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+
+	iv, mac, err := LegacySharedSecret(own, &peer.PublicKey, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{225, 102, 1, 16, 216, 133, 101, 222, 56, 7, 168, 175, 197, 221, 224, 115, 187, 160, 200, 20}, iv)
+	assert.Equal(t, []byte{232, 122, 58, 134, 120, 47, 121, 36}, mac)
+
+	// both sides compute the same secret
+	iv2, mac2, err := LegacySharedSecret(peer, &own.PublicKey, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, iv, iv2)
+	assert.Equal(t, mac, mac2)
+
+	_, _, err = LegacySharedSecret(own, &peer.PublicKey, alpha, beta[:5])
+	assert.Error(t, err)
+	_, _, err = LegacySharedSecret(own, &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(1)}, alpha, beta)
+	assert.Error(t, err)
+}
+
+func TestLegacySession(t *testing.T) {
+	own, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	peer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	alpha := make([]byte, 10)
+	beta := make([]byte, 10)
+
+	s, err := NewLegacySession(own, &peer.PublicKey, alpha, beta)
+	assert.NoError(t, err)
+	assert.Len(t, s.SharedIV, 20)
+	assert.Len(t, s.SharedMAC, 8)
+}
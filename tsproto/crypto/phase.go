@@ -0,0 +1,182 @@
+package crypto
+
+import (
+	"bytes"
+	"sync"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+// Phase is the crypto phase of a connection
+type Phase int
+
+const (
+	// PhaseInit is the low-level handshake, only Init1 packets are exchanged,
+	// they are not encrypted and carry the MAC "TS3INIT1"
+	PhaseInit Phase = iota
+	// PhaseFake starts with clientinitiv, packets are encrypted with the
+	// default key and nonce until the keys are negotiated
+	PhaseFake
+	// PhaseNegotiated starts with clientek, packets are encrypted with the keys
+	// of the Session and the packets not encrypted carry the SharedMAC
+	PhaseNegotiated
+)
+
+const initMAC = "TS3INIT1"
+
+var (
+	// DefaultKey is the key of the fake encryption, "c:\windows\syste"
+	DefaultKey = []byte{0x63, 0x3A, 0x5C, 0x77, 0x69, 0x6E, 0x64, 0x6F, 0x77, 0x73, 0x5C, 0x73, 0x79, 0x73, 0x74, 0x65}
+	// DefaultNonce is the nonce of the fake encryption, "m\firewall32.cpl"
+	DefaultNonce = []byte{0x6D, 0x5C, 0x66, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6C, 0x6C, 0x33, 0x32, 0x2E, 0x63, 0x70, 0x6C}
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseInit:
+		return "init"
+	case PhaseFake:
+		return "fake"
+	case PhaseNegotiated:
+		return "negotiated"
+	default:
+		return "unknown"
+	}
+}
+
+// Crypt seals and opens the packets of a connection according to its crypto phase,
+// packets which do not belong to the current phase are rejected
+type Crypt struct {
+	mu      sync.RWMutex
+	phase   Phase
+	session *Session
+}
+
+// NewCrypt creates a Crypt in PhaseInit
+func NewCrypt() *Crypt {
+	return &Crypt{}
+}
+
+// Phase returns the current crypto phase
+func (c *Crypt) Phase() Phase {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.phase
+}
+
+// Session returns the negotiated session, nil before PhaseNegotiated
+func (c *Crypt) Session() *Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session
+}
+
+// StartFake moves from PhaseInit to PhaseFake, it is called once clientinitiv
+// has been sent or received
+func (c *Crypt) StartFake() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.phase == PhaseInit {
+		c.phase = PhaseFake
+	}
+}
+
+// Negotiate moves to PhaseNegotiated with the keys of the session, a nil session
+// is rejected and the phase is kept
+func (c *Crypt) Negotiate(session *Session) error {
+	if session == nil {
+		return tsErrors.Errorf(tsErrors.InvalidSession, "nil session")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = session
+	c.phase = PhaseNegotiated
+	return nil
+}
+
+// Seal encrypts a marshaled packet of the direction for the current phase, the
+// packets not encrypted get the MAC of the phase written to the header
+func (c *Crypt) Seal(raw []byte, generation uint32, direction packets.PacketDirection) ([]byte, error) {
+	t, _, headerSize, err := packetHeader(raw, direction)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := raw[headerSize-1]&flagUnencrypted == 0
+
+	c.mu.RLock()
+	phase, session := c.phase, c.session
+	c.mu.RUnlock()
+	if err := checkPhase(phase, t, encrypted); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t == packets.PacketTypeInit1:
+		packet := append([]byte{}, raw...)
+		copy(packet[:8], initMAC)
+		return packet, nil
+	case !encrypted:
+		packet := append([]byte{}, raw...)
+		copy(packet[:8], session.SharedMAC)
+		return packet, nil
+	case phase == PhaseFake:
+		return seal(raw, headerSize, DefaultKey, DefaultNonce)
+	default:
+		return session.Seal(raw, generation, direction)
+	}
+}
+
+// Open checks and decrypts a packet of the direction for the current phase,
+// the returned packet holds the plain payload and can be decoded by packets.Decode
+func (c *Crypt) Open(raw []byte, generation uint32, direction packets.PacketDirection) ([]byte, error) {
+	t, packetId, headerSize, err := packetHeader(raw, direction)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := raw[headerSize-1]&flagUnencrypted == 0
+
+	c.mu.RLock()
+	phase, session := c.phase, c.session
+	c.mu.RUnlock()
+	if err := checkPhase(phase, t, encrypted); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t == packets.PacketTypeInit1:
+		if string(raw[:8]) != initMAC {
+			return nil, tsErrors.Errorf(tsErrors.DecryptFailed, t, packetId)
+		}
+		return raw, nil
+	case !encrypted:
+		if !bytes.Equal(raw[:8], session.SharedMAC) {
+			return nil, tsErrors.Errorf(tsErrors.DecryptFailed, t, packetId)
+		}
+		return raw, nil
+	case phase == PhaseFake:
+		return open(raw, headerSize, DefaultKey, DefaultNonce)
+	default:
+		return session.Open(raw, generation, direction)
+	}
+}
+
+// checkPhase rejects the packets which are not allowed in the phase:
+// Init1 packets are never encrypted and end with PhaseNegotiated, all other
+// packets need PhaseFake and may only skip encryption with PhaseNegotiated
+func checkPhase(phase Phase, t packets.PacketType, encrypted bool) error {
+	var ok bool
+	switch {
+	case t == packets.PacketTypeInit1:
+		// Init4 may be resent after clientinitiv
+		ok = !encrypted && phase != PhaseNegotiated
+	case !encrypted:
+		ok = phase == PhaseNegotiated
+	default:
+		ok = phase != PhaseInit
+	}
+	if !ok {
+		return tsErrors.Errorf(tsErrors.WrongCryptoPhase, phase, t, encrypted)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/eax"
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+func marshalPacket(t *testing.T, p packets.Packet) []byte {
+	raw, err := p.Marshal()
+	assert.NoError(t, err)
+	return raw
+}
+
+func TestCryptPhases(t *testing.T) {
+	c := NewCrypt()
+	assert.Equal(t, PhaseInit, c.Phase())
+
+	init0 := marshalPacket(t, &packets.Init0Packet{})
+	command := marshalPacket(t, &packets.CommandPacket{
+		C2S: &packets.C2SPacket{
+			PacketId:    1,
+			Encrypted:   true,
+			NewProtocol: true,
+			PacketType:  packets.PacketTypeCommand,
+		},
+		Command: &packets.Command{Name: "clientek"},
+	})
+	ping := marshalPacket(t, &packets.PingPacket{
+		C2S: &packets.C2SPacket{PacketId: 1, PacketType: packets.PacketTypePing},
+	})
+
+	// init phase, only Init1 packets
+	opened, err := c.Open(init0, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	assert.Equal(t, init0, opened)
+	_, err = c.Seal(command, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrWrongCryptoPhase))
+	_, err = c.Seal(ping, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrWrongCryptoPhase))
+
+	// fake phase, encrypted with the default key
+	c.StartFake()
+	assert.Equal(t, PhaseFake, c.Phase())
+	fake, err := c.Seal(command, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	block, _ := aes.NewCipher(DefaultKey)
+	aead, _ := eax.NewEAXWithNonceAndTagSize(block, 16, 8)
+	expect := aead.Seal(nil, DefaultNonce, command[packets.C2SHeaderSize:], command[8:packets.C2SHeaderSize])
+	assert.Equal(t, expect[len(expect)-8:], fake[:8])
+	assert.Equal(t, expect[:len(expect)-8], fake[packets.C2SHeaderSize:])
+	opened, err = c.Open(fake, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	assert.Equal(t, command[8:], opened[8:])
+	_, err = c.Open(init0, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	_, err = c.Seal(ping, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrWrongCryptoPhase))
+
+	// negotiated phase, the fake encrypted packets do not open anymore
+	session := testSession(t)
+	err = c.Negotiate(nil)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidSession))
+	assert.Equal(t, PhaseFake, c.Phase())
+	assert.NoError(t, c.Negotiate(session))
+	assert.Equal(t, PhaseNegotiated, c.Phase())
+	assert.Equal(t, session, c.Session())
+	_, err = c.Open(fake, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrDecryptFailed))
+	_, err = c.Open(init0, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrWrongCryptoPhase))
+
+	sealed, err := c.Seal(command, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	opened, err = c.Open(sealed, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	assert.Equal(t, command[8:], opened[8:])
+
+	// packets not encrypted carry the SharedMAC
+	signed, err := c.Seal(ping, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	assert.Equal(t, session.SharedMAC, signed[:8])
+	_, err = c.Open(signed, 0, packets.PacketDirectionC2S)
+	assert.NoError(t, err)
+	_, err = c.Open(ping, 0, packets.PacketDirectionC2S)
+	assert.True(t, errors.Is(err, tsErrors.ErrDecryptFailed))
+}
@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"sync"
+
+	"filippo.io/edwards25519"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+// Session holds the secrets negotiated by the handshake of a connection,
+// it derives the key and nonce of every encrypted packet
+type Session struct {
+	// 64 bytes : The shared IV, the base of all packet keys
+	SharedIV []byte
+	// 08 bytes : The shared MAC, used as MAC of the packets not encrypted
+	SharedMAC []byte
+
+	mu    sync.Mutex
+	cache [2][packetTypeCount][2]baseKeyNonce
+}
+
+const packetTypeCount = int(packets.PacketTypeInit1) + 1
+
+// baseKeyNonce is the key and nonce of a generation before the packet id is xored in
+type baseKeyNonce struct {
+	valid      bool
+	generation uint32
+	keyNonce   [32]byte
+}
+
+// NewSession derives the session secrets from the own ephemeral private key, the
+// ephemeral public key of the peer and the alpha and beta of the handshake
+func NewSession(privateKey, publicKey, alpha, beta []byte) (*Session, error) {
+	sharedIV, sharedMAC, err := SharedSecret(privateKey, publicKey, alpha, beta)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{SharedIV: sharedIV, SharedMAC: sharedMAC}, nil
+}
+
+// SharedSecret computes the SharedIV and SharedMAC from the Ed25519 key exchange,
+// SharedIV = sha512(privateKey * publicKey) ^ alpha ^ beta (at offset 10)
+// and SharedMAC = sha1(SharedIV)[0:8]
+func SharedSecret(privateKey, publicKey, alpha, beta []byte) ([]byte, []byte, error) {
+	// alpha comes from clientinitiv and beta from initivexpand2 of the peer
+	if len(alpha) != 10 || len(beta) != 54 {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidCommand, "alpha must be 10 and beta 54 bytes")
+	}
+	scalar, err := new(edwards25519.Scalar).SetCanonicalBytes(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedData := point.ScalarMult(scalar, point).Bytes()
+
+	sharedIV := sha512.Sum512(sharedData[:32])
+	for i := range alpha {
+		sharedIV[i] ^= alpha[i]
+	}
+	for i := range beta {
+		sharedIV[10+i] ^= beta[i]
+	}
+
+	macHash := sha1.Sum(sharedIV[:])
+	return sharedIV[:], macHash[:8], nil
+}
+
+// KeyNonce returns the key and nonce of a packet,
+// sha256(direction | type | generation | SharedIV) split in key and nonce
+// with the packet id xored into the first two bytes of the key.
+// The hash is cached for the current and the previous generation of every
+// type and direction, so only the xor is done per packet.
+func (s *Session) KeyNonce(t packets.PacketType, packetId uint16, generation uint32, direction packets.PacketDirection) ([]byte, []byte) {
+	keyNonce := s.baseKeyNonce(t, generation, direction)
+	key, nonce := keyNonce[:16], keyNonce[16:32]
+	key[0] ^= byte(packetId >> 8)
+	key[1] ^= byte(packetId)
+	return key, nonce
+}
+
+// baseKeyNonce returns the cached hash of the type, generation and direction,
+// the slot of the oldest generation is replaced on a miss
+func (s *Session) baseKeyNonce(t packets.PacketType, generation uint32, direction packets.PacketDirection) [32]byte {
+	if t < 0 || int(t) >= packetTypeCount || direction < 0 || direction > 1 {
+		return deriveKeyNonce(s.SharedIV, t, generation, direction)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots := &s.cache[direction][t]
+	for i := range slots {
+		if slots[i].valid && slots[i].generation == generation {
+			return slots[i].keyNonce
+		}
+	}
+	oldest := 0
+	if slots[0].valid && (!slots[1].valid || slots[1].generation < slots[0].generation) {
+		oldest = 1
+	}
+	slots[oldest] = baseKeyNonce{
+		valid:      true,
+		generation: generation,
+		keyNonce:   deriveKeyNonce(s.SharedIV, t, generation, direction),
+	}
+	return slots[oldest].keyNonce
+}
+
+// deriveKeyNonce hashes the direction, type, generation and SharedIV
+func deriveKeyNonce(sharedIV []byte, t packets.PacketType, generation uint32, direction packets.PacketDirection) [32]byte {
+	temporary := make([]byte, 6+len(sharedIV))
+	if direction == packets.PacketDirectionS2C {
+		temporary[0] = 0x30
+	} else {
+		temporary[0] = 0x31
+	}
+	temporary[1] = byte(t)
+	binary.BigEndian.PutUint32(temporary[2:6], generation)
+	copy(temporary[6:], sharedIV)
+	return sha256.Sum256(temporary)
+}
+
+// Seal encrypts a marshaled packet of the direction, it returns the packet with the
+// payload encrypted and the 8 bytes MAC written to the header
+func (s *Session) Seal(raw []byte, generation uint32, direction packets.PacketDirection) ([]byte, error) {
+	t, packetId, headerSize, err := packetHeader(raw, direction)
+	if err != nil {
+		return nil, err
+	}
+	key, nonce := s.KeyNonce(t, packetId, generation, direction)
+	return seal(raw, headerSize, key, nonce)
+}
+
+// Open decrypts a packet of the direction and checks its MAC,
+// the returned packet holds the plain payload and can be decoded by packets.Decode
+func (s *Session) Open(raw []byte, generation uint32, direction packets.PacketDirection) ([]byte, error) {
+	t, packetId, headerSize, err := packetHeader(raw, direction)
+	if err != nil {
+		return nil, err
+	}
+	key, nonce := s.KeyNonce(t, packetId, generation, direction)
+	return open(raw, headerSize, key, nonce)
+}
@@ -0,0 +1,176 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+func TestSharedSecret1(t *testing.T) {
+	clientEK := []byte{33, 130, 36, 111, 230, 193, 88, 163, 84, 149, 169, 240, 34, 95, 60, 204, 237, 38, 11, 27, 90, 63, 197, 50, 83, 66, 0, 2, 160, 86, 131, 4}
+	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
+	alpha := []byte{126, 211, 35, 127, 243, 201, 91, 73, 76, 236}
+	beta := []byte{9, 175, 214, 76, 55, 7, 129, 96, 92, 61, 39, 36, 187, 26, 232, 62, 144, 168, 180, 221, 237, 205, 17, 219, 78, 149, 161, 51, 56, 172, 5, 249, 157, 219, 215, 62, 74, 193, 128, 157, 155, 9, 91, 255, 217, 111, 7, 65, 179, 212, 190, 218, 219, 33}
+	expectIV := []byte{148, 105, 180, 62, 107, 35, 13, 131, 151, 216, 140, 157, 127, 78, 88, 57, 126, 50, 148, 198, 66, 46, 23, 241, 238, 172, 143, 168, 119, 190, 67, 17, 14, 230, 193, 82, 82, 84, 192, 34, 11, 28, 250, 151, 96, 69, 248, 74, 54, 242, 129, 24, 142, 18, 62, 72, 187, 191, 172, 229, 154, 194, 224, 109}
+	expectMAC := []byte{226, 171, 133, 70, 51, 56, 72, 48}
+	iv, mac, err := SharedSecret(clientEK, serverEK, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, expectIV, iv)
+	assert.Equal(t, expectMAC, []byte(mac))
+}
+
+func TestSharedSecret2(t *testing.T) {
+	clientEK := []byte{141, 13, 243, 209, 90, 237, 241, 67, 215, 37, 172, 156, 157, 64, 26, 67, 202, 4, 248, 243, 195, 201, 210, 116, 215, 205, 29, 96, 220, 131, 220, 12}
+	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
+	alpha := []byte{202, 230, 0, 52, 22, 180, 158, 138, 106, 250}
+	beta := []byte{104, 48, 226, 127, 0, 28, 191, 66, 77, 76, 42, 20, 26, 60, 175, 156, 46, 54, 37, 178, 83, 249, 250, 194, 17, 181, 34, 204, 121, 221, 212, 129, 41, 178, 25, 58, 192, 18, 80, 211, 90, 125, 31, 46, 111, 81, 247, 219, 43, 103, 62, 2, 80, 70}
+	expectIV := []byte{65, 141, 2, 3, 44, 254, 84, 249, 139, 150, 45, 139, 66, 94, 164, 208, 4, 19, 210, 65, 113, 157, 222, 190, 2, 49, 169, 240, 172, 52, 172, 242, 51, 90, 98, 100, 160, 242, 104, 240, 98, 178, 240, 170, 165, 229, 146, 188, 195, 35, 200, 113, 125, 5, 61, 254, 27, 244, 209, 70, 175, 142, 27, 117}
+	expectMAC := []byte{165, 35, 211, 116, 142, 52, 222, 251}
+	iv, mac, err := SharedSecret(clientEK, serverEK, alpha, beta)
+	assert.NoError(t, err)
+	assert.Equal(t, expectIV, iv)
+	assert.Equal(t, expectMAC, []byte(mac))
+}
+
+func TestSharedSecretLengths(t *testing.T) {
+	clientEK := []byte{33, 130, 36, 111, 230, 193, 88, 163, 84, 149, 169, 240, 34, 95, 60, 204, 237, 38, 11, 27, 90, 63, 197, 50, 83, 66, 0, 2, 160, 86, 131, 4}
+	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
+
+	// a hostile peer sends alpha or beta of any length
+	for _, lengths := range [][2]int{{65, 54}, {9, 54}, {10, 55}, {10, 0}} {
+		_, _, err := SharedSecret(clientEK, serverEK, make([]byte, lengths[0]), make([]byte, lengths[1]))
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidCommand), lengths)
+	}
+}
+
+func testSession(t *testing.T) *Session {
+	clientEK := []byte{33, 130, 36, 111, 230, 193, 88, 163, 84, 149, 169, 240, 34, 95, 60, 204, 237, 38, 11, 27, 90, 63, 197, 50, 83, 66, 0, 2, 160, 86, 131, 4}
+	serverEK := []byte{10, 133, 213, 227, 26, 212, 241, 246, 129, 40, 156, 188, 38, 31, 102, 0, 237, 222, 136, 59, 134, 196, 84, 119, 170, 19, 123, 131, 27, 169, 97, 196}
+	alpha := []byte{126, 211, 35, 127, 243, 201, 91, 73, 76, 236}
+	beta := []byte{9, 175, 214, 76, 55, 7, 129, 96, 92, 61, 39, 36, 187, 26, 232, 62, 144, 168, 180, 221, 237, 205, 17, 219, 78, 149, 161, 51, 56, 172, 5, 249, 157, 219, 215, 62, 74, 193, 128, 157, 155, 9, 91, 255, 217, 111, 7, 65, 179, 212, 190, 218, 219, 33}
+	s, err := NewSession(clientEK, serverEK, alpha, beta)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestSessionKeyNonce(t *testing.T) {
+	s := testSession(t)
+	key, nonce := s.KeyNonce(packets.PacketTypeCommand, 0x0102, 1, packets.PacketDirectionC2S)
+	assert.Equal(t, []byte{80, 204, 208, 224, 99, 177, 64, 209, 34, 155, 150, 173, 9, 233, 33, 199}, key)
+	assert.Equal(t, []byte{157, 79, 177, 94, 39, 218, 67, 104, 220, 8, 190, 253, 27, 60, 2, 17}, nonce)
+
+	// every direction has its own keys
+	key2, _ := s.KeyNonce(packets.PacketTypeCommand, 0x0102, 1, packets.PacketDirectionS2C)
+	assert.NotEqual(t, key, key2)
+}
+
+func TestSessionSealOpen(t *testing.T) {
+	s := testSession(t)
+	cp := packets.CommandPacket{
+		S2C: &packets.S2CPacket{
+			PacketId:    7,
+			Encrypted:   true,
+			NewProtocol: true,
+			PacketType:  packets.PacketTypeCommand,
+		},
+		Command: &packets.Command{Name: "clientinit", Params: map[string]string{"client_nickname": "test"}},
+	}
+	raw, err := cp.Marshal()
+	assert.NoError(t, err)
+
+	sealed, err := s.Seal(raw, 0, packets.PacketDirectionS2C)
+	assert.NoError(t, err)
+	assert.Equal(t, len(raw), len(sealed))
+	assert.Equal(t, raw[8:packets.S2CHeaderSize], sealed[8:packets.S2CHeaderSize])
+	assert.NotEqual(t, raw[packets.S2CHeaderSize:], sealed[packets.S2CHeaderSize:])
+
+	opened, err := s.Open(sealed, 0, packets.PacketDirectionS2C)
+	assert.NoError(t, err)
+	assert.Equal(t, sealed[:8], opened[:8])
+	assert.Equal(t, raw[8:], opened[8:])
+
+	decoded := &packets.CommandPacket{PacketDirection: packets.PacketDirectionS2C}
+	assert.NoError(t, decoded.Unmarshal(opened))
+	assert.Equal(t, cp.Command, decoded.Command)
+
+	// the wrong generation, direction or a tampered header fail
+	_, err = s.Open(sealed, 1, packets.PacketDirectionS2C)
+	assert.True(t, errors.Is(err, tsErrors.ErrDecryptFailed))
+	tampered := append([]byte{}, sealed...)
+	tampered[9] ^= 1
+	_, err = s.Open(tampered, 0, packets.PacketDirectionS2C)
+	assert.True(t, errors.Is(err, tsErrors.ErrDecryptFailed))
+	_, err = s.Open(sealed[:5], 0, packets.PacketDirectionS2C)
+	assert.True(t, errors.Is(err, tsErrors.ErrPacketIncomplete))
+}
+
+func TestSessionKeyNonceCache(t *testing.T) {
+	s := testSession(t)
+	uncached := func(pt packets.PacketType, packetId uint16, generation uint32, direction packets.PacketDirection) ([]byte, []byte) {
+		keyNonce := deriveKeyNonce(s.SharedIV, pt, generation, direction)
+		keyNonce[0] ^= byte(packetId >> 8)
+		keyNonce[1] ^= byte(packetId)
+		return keyNonce[:16], keyNonce[16:]
+	}
+
+	for _, generation := range []uint32{0, 1, 0, 2, 1, 0, 3} {
+		for _, direction := range []packets.PacketDirection{packets.PacketDirectionC2S, packets.PacketDirectionS2C} {
+			for _, pt := range []packets.PacketType{packets.PacketTypeVoice, packets.PacketTypeCommand, packets.PacketTypeAck} {
+				for _, packetId := range []uint16{0, 1, 0x0102, 65535} {
+					key, nonce := s.KeyNonce(pt, packetId, generation, direction)
+					expectKey, expectNonce := uncached(pt, packetId, generation, direction)
+					assert.Equal(t, expectKey, key)
+					assert.Equal(t, expectNonce, nonce)
+				}
+			}
+		}
+	}
+
+	// the returned key does not alias the cache
+	key, _ := s.KeyNonce(packets.PacketTypeCommand, 0, 0, packets.PacketDirectionC2S)
+	key[0] ^= 0xff
+	key2, _ := s.KeyNonce(packets.PacketTypeCommand, 0, 0, packets.PacketDirectionC2S)
+	assert.NotEqual(t, key, key2)
+}
+
+func BenchmarkKeyNonce(b *testing.B) {
+	s := &Session{SharedIV: make([]byte, 64)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.KeyNonce(packets.PacketTypeVoice, uint16(i), 0, packets.PacketDirectionS2C)
+	}
+}
+
+func BenchmarkKeyNonceUncached(b *testing.B) {
+	sharedIV := make([]byte, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		keyNonce := deriveKeyNonce(sharedIV, packets.PacketTypeVoice, 0, packets.PacketDirectionS2C)
+		keyNonce[0] ^= byte(i >> 8)
+		keyNonce[1] ^= byte(i)
+	}
+}
+
+func BenchmarkSessionSeal(b *testing.B) {
+	s := &Session{SharedIV: make([]byte, 64)}
+	raw, err := packets.VoicePacket{
+		S2C: &packets.S2CPacket{
+			Encrypted:  true,
+			PacketType: packets.PacketTypeVoice,
+		},
+		Codec: packets.CodecOpusVoice,
+		Data:  make([]byte, 100),
+	}.Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = s.Seal(raw, 0, packets.PacketDirectionS2C)
+	}
+}
@@ -1,8 +1,71 @@
 package errors
 
+import (
+	"fmt"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
 const (
 	PacketIncomplete      = "packet incomplete, size: %d but expect %v"
 	PacketTypeUnmatched   = "packet type unmatched, type: %d but expect %d"
 	PacketLowInitDisorder = "low-level init packet disorder, stage: %d but expect %d"
 	InvalidCommand        = "invalid command, reason: %s"
+	UnknownPacketType     = "unknown packet type: %d"
+	UnknownLowInitStage   = "unknown low-level init stage: %d"
+	InvalidASN1Omega      = "invalid ASN.1 omega, reason: %s"
+	InvalidLicense        = "invalid license, reason: %s"
+	NotImplemented        = "not implemented: %s"
+	FragmentDisorder      = "command fragment disorder, packet id: %d but expect %d"
+	CommandTooLarge       = "command too large, size: %d but limit %d"
+	InvalidQuickLZ        = "invalid quicklz data, reason: %s"
+	InvalidPacket         = "invalid packet, reason: %s"
+	DecryptFailed         = "packet decryption failed, type: %d, packet id: %d"
+	WrongCryptoPhase      = "packet not allowed in crypto phase %s, type: %d, encrypted: %t"
+	InvalidSession        = "invalid crypto session, reason: %s"
+	InvalidPuzzle         = "invalid puzzle, reason: %s"
+)
+
+// Sentinel errors for matching the errors returned by tsproto with errors.Is,
+// e.g. errors.Is(err, ErrPacketIncomplete)
+var (
+	ErrPacketIncomplete      = &Error{format: PacketIncomplete}
+	ErrPacketTypeUnmatched   = &Error{format: PacketTypeUnmatched}
+	ErrPacketLowInitDisorder = &Error{format: PacketLowInitDisorder}
+	ErrInvalidCommand        = &Error{format: InvalidCommand}
+	ErrUnknownPacketType     = &Error{format: UnknownPacketType}
+	ErrUnknownLowInitStage   = &Error{format: UnknownLowInitStage}
+	ErrInvalidASN1Omega      = &Error{format: InvalidASN1Omega}
+	ErrInvalidLicense        = &Error{format: InvalidLicense}
+	ErrNotImplemented        = &Error{format: NotImplemented}
+	ErrFragmentDisorder      = &Error{format: FragmentDisorder}
+	ErrCommandTooLarge       = &Error{format: CommandTooLarge}
+	ErrInvalidQuickLZ        = &Error{format: InvalidQuickLZ}
+	ErrInvalidPacket         = &Error{format: InvalidPacket}
+	ErrDecryptFailed         = &Error{format: DecryptFailed}
+	ErrWrongCryptoPhase      = &Error{format: WrongCryptoPhase}
+	ErrInvalidSession        = &Error{format: InvalidSession}
+	ErrInvalidPuzzle         = &Error{format: InvalidPuzzle}
 )
+
+// Error is a typed tsproto error built from one of the formats above,
+// two errors are considered the same kind when they share the format
+type Error struct {
+	format string
+	args   []interface{}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf(e.format, e.args...)
+}
+
+// Is reports whether the target is the same kind of error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.format == e.format
+}
+
+// Errorf formats a typed error with a stack trace attached
+func Errorf(format string, args ...interface{}) error {
+	return pkgErrors.WithStack(&Error{format: format, args: args})
+}
@@ -3,8 +3,60 @@ package license
 import (
 	"bytes"
 	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 )
 
+type IntermediateBlock struct {
+	// 04 bytes : Unknown
+	Unknown uint32
+	// var bytes : A null terminated string, which describes the issuer of this certificate.
+	Issuer string
+}
+
+func (i IntermediateBlock) Marshal() ([]byte, error) {
+	unknown := make([]byte, 4)
+	binary.BigEndian.PutUint32(unknown, i.Unknown)
+	return bytes.Join([][]byte{unknown, []byte(i.Issuer), {0x00}}, []byte{}), nil
+}
+
+func (i *IntermediateBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, i.unmarshal)
+}
+
+// unmarshal parses the content at the start of raw and returns its size
+func (i *IntermediateBlock) unmarshal(raw []byte) (int, error) {
+	if len(raw) < 5 {
+		return 0, tsErrors.Errorf(tsErrors.InvalidLicense, "intermediate block too short")
+	}
+	issuer, size, err := unmarshalString(raw[4:])
+	if err != nil {
+		return 0, err
+	}
+	i.Unknown = binary.BigEndian.Uint32(raw[0:4])
+	i.Issuer = issuer
+	return 4 + size, nil
+}
+
+type WebsiteBlock struct {
+	// var bytes : A null terminated string, which describes the issuer of this certificate.
+	Issuer string
+}
+
+func (w WebsiteBlock) Marshal() ([]byte, error) {
+	return append([]byte(w.Issuer), 0x00), nil
+}
+
+func (w *WebsiteBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, w.unmarshal)
+}
+
+// unmarshal parses the content at the start of raw and returns its size
+func (w *WebsiteBlock) unmarshal(raw []byte) (size int, err error) {
+	w.Issuer, size, err = unmarshalString(raw)
+	return size, err
+}
+
 type ServerBlock struct {
 	// 01 bytes : Server License Type
 	ServerLicenseType byte
@@ -20,9 +72,42 @@ func (s ServerBlock) Marshal() ([]byte, error) {
 	return bytes.Join([][]byte{{s.ServerLicenseType}, unknown[0:], []byte(s.Issuer), {0x00}}, []byte{}), nil
 }
 
-func (s ServerBlock) Unmarshal(bytes []byte) error {
-	//TODO implement me
-	panic("implement me")
+func (s *ServerBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, s.unmarshal)
+}
+
+// unmarshal parses the content at the start of raw and returns its size
+func (s *ServerBlock) unmarshal(raw []byte) (int, error) {
+	if len(raw) < 6 {
+		return 0, tsErrors.Errorf(tsErrors.InvalidLicense, "server block too short")
+	}
+	issuer, size, err := unmarshalString(raw[5:])
+	if err != nil {
+		return 0, err
+	}
+	s.ServerLicenseType = raw[0]
+	s.Unknown = binary.BigEndian.Uint32(raw[1:5])
+	s.Issuer = issuer
+	return 5 + size, nil
+}
+
+type CodeBlock struct {
+	// var bytes : A null terminated string, which describes the issuer of this certificate.
+	Issuer string
+}
+
+func (c CodeBlock) Marshal() ([]byte, error) {
+	return append([]byte(c.Issuer), 0x00), nil
+}
+
+func (c *CodeBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, c.unmarshal)
+}
+
+// unmarshal parses the content at the start of raw and returns its size
+func (c *CodeBlock) unmarshal(raw []byte) (size int, err error) {
+	c.Issuer, size, err = unmarshalString(raw)
+	return size, err
 }
 
 type EphemeralBlock struct{}
@@ -31,13 +116,43 @@ func (e EphemeralBlock) Marshal() ([]byte, error) {
 	return []byte{}, nil
 }
 
-func (e EphemeralBlock) Unmarshal(i []byte) error {
-	//TODO implement me
-	panic("implement me")
+func (e *EphemeralBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, e.unmarshal)
+}
+
+// unmarshal parses the content at the start of raw, it is always empty
+func (e *EphemeralBlock) unmarshal(raw []byte) (int, error) {
+	return 0, nil
+}
+
+// RawBlock keeps the content of an unknown block type as is. Blocks have no
+// length field, so the content runs to the end of the license and the blocks
+// after it cannot be parsed, no key is derived from a chain containing one.
+type RawBlock struct {
+	// var bytes : The rest of the license after the block header
+	Data []byte
+}
+
+func (r RawBlock) Marshal() ([]byte, error) {
+	return append([]byte{}, r.Data...), nil
+}
+
+func (r *RawBlock) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, r.unmarshal)
+}
+
+// unmarshal takes all of raw as content and returns its size
+func (r *RawBlock) unmarshal(raw []byte) (int, error) {
+	r.Data = append([]byte{}, raw...)
+	return len(raw), nil
 }
 
-func (s ServerBlock) isLicenseBlockContent()    {}
-func (e EphemeralBlock) isLicenseBlockContent() {}
+func (i IntermediateBlock) isLicenseBlockContent() {}
+func (w WebsiteBlock) isLicenseBlockContent()      {}
+func (s ServerBlock) isLicenseBlockContent()       {}
+func (c CodeBlock) isLicenseBlockContent()         {}
+func (e EphemeralBlock) isLicenseBlockContent()    {}
+func (r RawBlock) isLicenseBlockContent()          {}
 
 func NewServerBlock(t byte) ServerBlock {
 	return ServerBlock{
@@ -0,0 +1,56 @@
+package license
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/license/licensetest"
+)
+
+func TestBlockUnmarshal(t *testing.T) {
+	lic := NewDefaultLicense()
+	for _, block := range lic.Blocks {
+		raw, err := block.Marshal()
+		assert.NoError(t, err)
+
+		parsed := Block{}
+		assert.NoError(t, parsed.Unmarshal(raw))
+		assert.Equal(t, block, parsed)
+
+		err = parsed.Unmarshal(append(raw, 0x00))
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	}
+
+	raw, _ := lic.Blocks[0].Marshal()
+	for _, truncated := range [][]byte{nil, raw[:blockHeaderSize-1], raw[:blockHeaderSize+5], raw[:len(raw)-1]} {
+		err := (&Block{}).Unmarshal(truncated)
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	}
+}
+
+func FuzzLicense(f *testing.F) {
+	lic := NewDefaultLicense()
+	raw, _ := lic.Marshal()
+	f.Add(raw)
+	raw, _ = base64.StdEncoding.DecodeString(licensetest.ServerLicense)
+	f.Add(raw)
+	f.Add([]byte{0x01})
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		l := License{}
+		if err := l.Unmarshal(raw); err != nil {
+			return
+		}
+		remarshaled, err := l.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, remarshaled) {
+			t.Fatalf("remarshaled license %x differs from %x", remarshaled, raw)
+		}
+	})
+}
@@ -3,18 +3,32 @@ package license
 import (
 	"bytes"
 	"crypto/ed25519"
-	"crypto/sha512"
 	"encoding/binary"
 	"fmt"
-	"strconv"
+	"time"
 
 	"filippo.io/edwards25519"
 
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 	"github.com/bzp2010/ts3protocol/tsproto/packets"
 )
 
 const (
 	ValidDataDifference = 1356998400
+
+	BlockTypeIntermediate = 0
+	BlockTypeWebsite      = 1
+	BlockTypeServer       = 2
+	BlockTypeCode         = 3
+	BlockTypeToken        = 4
+	BlockTypeLicenseSign  = 5
+	BlockTypeMyTsIdSign   = 6
+	BlockTypeUpdater      = 7
+	BlockTypeEphemeral    = 32
+
+	// blockHeaderSize is the size of a block without content
+	// KeyType(1) + PublicKey(32) + BlockType(1) + NotBefore(4) + NotAfter(4)
+	blockHeaderSize = 42
 )
 
 var (
@@ -67,70 +81,47 @@ func (f fixedReader) Read(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// nextKeypair derives the key of the chain from rootKey without checking the
+// validity windows, it returns the scalar of the last block and the derived public key
 func (l *License) nextKeypair() (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	if len(l.Blocks) == 0 {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidLicense, "no blocks")
+	}
+
 	var (
-		parent = new(edwards25519.Point).Set(rootKey) // Next publicKey
-		b      []byte                                 // Raw block data
-		scalar *edwards25519.Scalar                   // Generated privateKey
-		//point  *edwards25519.Point
-		err error
+		parent = new(edwards25519.Point).Set(rootKey)
+		scalar *edwards25519.Scalar
+		err    error
 	)
-
-	fmt.Println("计算Keypair, License块数量", len(l.Blocks))
-
-	for i, block := range l.Blocks {
-		fmt.Println("计算Keypair, License块数量", len(l.Blocks), "当前第"+strconv.Itoa(i+1)+"块")
-		b, err = block.Marshal()
+	for _, block := range l.Blocks {
+		parent, scalar, err = deriveKey(parent, block)
 		if err != nil {
 			return nil, nil, err
 		}
-
-		hash := sha512.Sum512(b[1:])
-		scalar, err = new(edwards25519.Scalar).SetBytesWithClamping(hash[:32])
-		if err != nil {
-			panic(err)
-		}
-
-		currentPoint, err := new(edwards25519.Point).SetBytes(block.PublicKey)
-		if err != nil {
-			panic(err)
-		}
-		nextPoint := currentPoint.ScalarMult(scalar, currentPoint)
-		parent = parent.Add(nextPoint, parent)
-
-		fmt.Print("当前点")
-		fmt.Println(currentPoint.ExtendedCoordinates())
-		fmt.Print("下一个点 ")
-		fmt.Println(parent.ExtendedCoordinates())
 	}
-
 	return scalar.Bytes(), parent.Bytes(), nil
+}
 
-	/*for _, block := range l.Blocks {
-		b, err = block.Marshal()
-		if err != nil {
-			return nil, nil, err
-		}
-
-		privateKey = *(*[32]byte)(b[1:])
-		privateKey[0] &= 248
-		privateKey[31] &= 127
-		privateKey[31] |= 64
-
-		// Generate relative public key
-		curve25519.ScalarBaseMult(&publicKey, &privateKey)
-
-		next, err := curve25519.X25519(privateKey[:], publicKey[:])
-		if err != nil {
-			return nil, nil, err
-		}
-
-		nextPoint, err := new(edwards25519.Point).SetBytes(next)
-		parent = nextPoint.Add(nextPoint, parent)
-		parent.Bytes()
-		fmt.Print("下一个点 ")
-		fmt.Println(parent.ExtendedCoordinates())
-	}*/
+// deriveKey adds the block key multiplied by the clamped hash of the block to parent,
+// the hash is sha512 of the block without its key type and it returns the scalar as well
+func deriveKey(parent *edwards25519.Point, block Block) (*edwards25519.Point, *edwards25519.Scalar, error) {
+	if _, ok := block.Content.(RawBlock); ok {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidLicense, fmt.Sprintf("unknown block type %d", block.BlockType))
+	}
+	b, err := block.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	scalar, err := new(edwards25519.Scalar).SetBytesWithClamping(licenseHash(b[1:]))
+	if err != nil {
+		return nil, nil, err
+	}
+	blockKey, err := new(edwards25519.Point).SetBytes(block.PublicKey)
+	if err != nil {
+		return nil, nil, tsErrors.Errorf(tsErrors.InvalidLicense, "invalid block public key")
+	}
+	next := new(edwards25519.Point).ScalarMult(scalar, blockKey)
+	return next.Add(next, parent), scalar, nil
 }
 
 // AddLicenseBlock will add a block to license
@@ -154,6 +145,49 @@ func (l *License) AddLicenseBlock(b Block) {
 	l.Blocks = append(l.Blocks, *ptr)
 }
 
+// Unmarshal parses a license and its chain of blocks
+func (l *License) Unmarshal(raw []byte) error {
+	if len(raw) < 1 {
+		return tsErrors.Errorf(tsErrors.InvalidLicense, "empty license")
+	}
+	if raw[0] != 0x01 {
+		return tsErrors.Errorf(tsErrors.InvalidLicense, fmt.Sprintf("unsupported version %d", raw[0]))
+	}
+
+	var blocks []Block
+	for offset := 1; offset < len(raw); {
+		block := Block{}
+		size, err := block.unmarshal(raw[offset:])
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+		offset += size
+	}
+	if len(blocks) == 0 {
+		return tsErrors.Errorf(tsErrors.InvalidLicense, "no blocks")
+	}
+
+	l.LicenseVersion = raw[0]
+	l.Blocks = blocks
+	return nil
+}
+
+// Verify checks the validity window of every block at now and derives the public
+// key of the chain from rootKey, the key of the last block is the server ephemeral key
+func (l *License) Verify(now time.Time) (ed25519.PublicKey, error) {
+	for i, block := range l.Blocks {
+		if !block.ValidAt(now) {
+			return nil, tsErrors.Errorf(tsErrors.InvalidLicense, fmt.Sprintf("block %d not valid at %s", i, now.UTC().Format(time.RFC3339)))
+		}
+	}
+	_, publicKey, err := l.nextKeypair()
+	if err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
 func (l *License) Marshal() ([]byte, error) {
 	data := []byte{l.LicenseVersion}
 	for _, block := range l.Blocks {
@@ -208,13 +242,71 @@ func (b Block) Marshal() ([]byte, error) {
 	return bytes.Join([][]byte{{b.KeyType}, b.PublicKey, {b.BlockType}, minimum, maximum, content}, []byte{}), nil
 }
 
-func (b Block) Unmarshal(bytes []byte) error {
-	//TODO implement me
-	panic("implement me")
+// ValidAt reports whether now is inside the validity window of the block
+func (b Block) ValidAt(now time.Time) bool {
+	notBefore := time.Unix(int64(b.MinimumValidData)+ValidDataDifference, 0)
+	notAfter := time.Unix(int64(b.MaximumValidData)+ValidDataDifference, 0)
+	return !now.Before(notBefore) && !now.After(notAfter)
+}
+
+func (b *Block) Unmarshal(raw []byte) error {
+	return unmarshalWhole(raw, b.unmarshal)
+}
+
+// unmarshal parses the block at the start of raw and returns its size
+func (b *Block) unmarshal(raw []byte) (int, error) {
+	if len(raw) < blockHeaderSize {
+		return 0, tsErrors.Errorf(tsErrors.InvalidLicense, "block too short")
+	}
+	if raw[0] != 0x00 {
+		return 0, tsErrors.Errorf(tsErrors.InvalidLicense, fmt.Sprintf("unsupported key type %d", raw[0]))
+	}
+	content, size, err := unmarshalContent(raw[33], raw[blockHeaderSize:])
+	if err != nil {
+		return 0, err
+	}
+	b.KeyType = raw[0]
+	b.PublicKey = append(ed25519.PublicKey{}, raw[1:33]...)
+	b.BlockType = raw[33]
+	b.MinimumValidData = binary.BigEndian.Uint32(raw[34:38])
+	b.MaximumValidData = binary.BigEndian.Uint32(raw[38:42])
+	b.Content = content
+	return blockHeaderSize + size, nil
+}
+
+// unmarshalContent parses the content of the block type at the start of raw
+func unmarshalContent(blockType byte, raw []byte) (BlockContent, int, error) {
+	switch blockType {
+	case BlockTypeIntermediate:
+		content := IntermediateBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	case BlockTypeWebsite:
+		content := WebsiteBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	case BlockTypeServer:
+		content := ServerBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	case BlockTypeCode:
+		content := CodeBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	case BlockTypeEphemeral:
+		content := EphemeralBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	case BlockTypeToken, BlockTypeLicenseSign, BlockTypeMyTsIdSign, BlockTypeUpdater:
+		return nil, 0, tsErrors.Errorf(tsErrors.InvalidLicense, fmt.Sprintf("block type %d has no known layout", blockType))
+	default:
+		content := RawBlock{}
+		size, err := content.unmarshal(raw)
+		return content, size, err
+	}
 }
 
 type BlockContent interface {
 	packets.Marshaler
-	packets.Unmarshaler
 	isLicenseBlockContent()
 }
@@ -1,9 +1,19 @@
 package license
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
 	"testing"
+	"time"
 
+	"filippo.io/edwards25519"
 	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/license/licensetest"
 )
 
 func TestNewDefaultLicense(t *testing.T) {
@@ -12,3 +22,174 @@ func TestNewDefaultLicense(t *testing.T) {
 	assert.Equal(t, rootKey.Bytes(), []byte(lic.Blocks[0].PublicKey))
 	//fmt.Println(lic.Blocks)
 }
+
+func TestLicenseUnmarshal(t *testing.T) {
+	lic := NewDefaultLicense()
+	raw, err := lic.Marshal()
+	assert.NoError(t, err)
+
+	parsed := &License{}
+	assert.NoError(t, parsed.Unmarshal(raw))
+	assert.Equal(t, lic, *parsed)
+
+	for _, truncated := range [][]byte{nil, {0x01}, {0x02}, raw[:len(raw)-3]} {
+		err = (&License{}).Unmarshal(truncated)
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	}
+}
+
+func TestBlockValidAt(t *testing.T) {
+	block := Block{MinimumValidData: 10, MaximumValidData: 20}
+	assert.False(t, block.ValidAt(time.Unix(ValidDataDifference+9, 0)))
+	assert.True(t, block.ValidAt(time.Unix(ValidDataDifference+10, 0)))
+	assert.True(t, block.ValidAt(time.Unix(ValidDataDifference+20, 0)))
+	assert.False(t, block.ValidAt(time.Unix(ValidDataDifference+21, 0)))
+}
+
+func TestLicenseUnmarshalServer(t *testing.T) {
+	raw, err := base64.StdEncoding.DecodeString(licensetest.ServerLicense)
+	assert.NoError(t, err)
+
+	lic := &License{}
+	assert.NoError(t, lic.Unmarshal(raw))
+	assert.Len(t, lic.Blocks, 2)
+	assert.Equal(t, byte(BlockTypeServer), lic.Blocks[0].BlockType)
+	assert.Equal(t, ServerBlock{ServerLicenseType: 7, Unknown: 32, Issuer: "Anonymous"}, lic.Blocks[0].Content)
+	assert.Equal(t, byte(BlockTypeEphemeral), lic.Blocks[1].BlockType)
+	assert.Equal(t, EphemeralBlock{}, lic.Blocks[1].Content)
+
+	remarshaled, err := lic.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, raw, remarshaled)
+}
+
+func TestLicenseUnmarshalBlockTypes(t *testing.T) {
+	blocks := []struct {
+		blockType byte
+		content   BlockContent
+	}{
+		{BlockTypeIntermediate, IntermediateBlock{Unknown: 37, Issuer: "TeamSpeak Systems GmbH"}},
+		{BlockTypeWebsite, WebsiteBlock{Issuer: "website"}},
+		{BlockTypeCode, CodeBlock{Issuer: "code"}},
+		{BlockTypeServer, NewServerBlock(7)},
+		{BlockTypeEphemeral, NewEphemeralBlock()},
+		// an unknown type takes the rest of the license
+		{99, RawBlock{Data: []byte("unknown\x00content")}},
+	}
+
+	lic := License{LicenseVersion: 0x01}
+	for i, block := range blocks {
+		lic.Blocks = append(lic.Blocks, Block{
+			PublicKey:        rootKey.Bytes(),
+			BlockType:        block.blockType,
+			MinimumValidData: uint32(i),
+			MaximumValidData: 1000,
+			Content:          block.content,
+		})
+	}
+	raw, err := lic.Marshal()
+	assert.NoError(t, err)
+
+	parsed := &License{}
+	assert.NoError(t, parsed.Unmarshal(raw))
+	assert.Equal(t, lic.Blocks, parsed.Blocks)
+	remarshaled, err := parsed.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, raw, remarshaled)
+
+	// no key is derived through an unknown type
+	_, _, err = parsed.GetServerEK()
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	parsed.Blocks = parsed.Blocks[:len(parsed.Blocks)-1]
+	_, _, err = parsed.GetServerEK()
+	assert.NoError(t, err)
+
+	// the types without known layout are rejected
+	first, last := lic.Blocks[0], lic.Blocks[4]
+	for _, blockType := range []byte{BlockTypeToken, BlockTypeLicenseSign, BlockTypeMyTsIdSign, BlockTypeUpdater} {
+		last.BlockType = blockType
+		lic.Blocks = []Block{first, last}
+		raw, err = lic.Marshal()
+		assert.NoError(t, err)
+		err = parsed.Unmarshal(raw)
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	}
+}
+
+func TestLicenseVerify(t *testing.T) {
+	raw, err := base64.StdEncoding.DecodeString(licensetest.ServerLicense)
+	assert.NoError(t, err)
+	lic := &License{}
+	assert.NoError(t, lic.Unmarshal(raw))
+
+	now := licensetest.ServerLicenseTime
+	key, err := lic.Verify(now)
+	assert.NoError(t, err)
+	assert.Equal(t, licensetest.ServerEK, key)
+
+	// the ephemeral block is valid for twelve hours only
+	_, err = lic.Verify(now.Add(72 * time.Hour))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+	_, err = lic.Verify(time.Unix(ValidDataDifference, 0))
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+
+	// any change of a block changes the derived key
+	lic.Blocks[0].Content = ServerBlock{ServerLicenseType: 7, Unknown: 32, Issuer: "Anonymouz"}
+	key, err = lic.Verify(now)
+	assert.NoError(t, err)
+	assert.NotEqual(t, licensetest.ServerEK, key)
+
+	_, err = (&License{LicenseVersion: 0x01}).Verify(now)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidLicense))
+}
+
+func TestLicenseVerifyIntermediate(t *testing.T) {
+	// a chain of a licensed server, the block keys are random points
+	blocks := []struct {
+		blockType byte
+		content   BlockContent
+	}{
+		{BlockTypeIntermediate, IntermediateBlock{Unknown: 5, Issuer: "TeamSpeak Systems GmbH"}},
+		{BlockTypeIntermediate, IntermediateBlock{Unknown: 37, Issuer: "Reseller"}},
+		{BlockTypeServer, ServerBlock{ServerLicenseType: 3, Unknown: 512, Issuer: "Licensee"}},
+		{BlockTypeEphemeral, NewEphemeralBlock()},
+	}
+	lic := &License{LicenseVersion: 0x01}
+	for _, block := range blocks {
+		random := make([]byte, 64)
+		_, err := rand.Read(random)
+		assert.NoError(t, err)
+		scalar, err := new(edwards25519.Scalar).SetUniformBytes(random)
+		assert.NoError(t, err)
+		lic.Blocks = append(lic.Blocks, Block{
+			PublicKey:        new(edwards25519.Point).ScalarBaseMult(scalar).Bytes(),
+			BlockType:        block.blockType,
+			MinimumValidData: 0,
+			MaximumValidData: 4294967295 - ValidDataDifference,
+			Content:          block.content,
+		})
+	}
+	raw, err := lic.Marshal()
+	assert.NoError(t, err)
+
+	// rootKey + sum(clamp(sha512(block without key type)[:32]) * block key), the
+	// blocks are cut out of the marshaled license by the sizes of their layouts
+	expected := new(edwards25519.Point).Set(rootKey)
+	offset := 1
+	for _, size := range []int{42 + 4 + 23, 42 + 4 + 9, 42 + 5 + 9, 42} {
+		sum := sha512.Sum512(raw[offset+1 : offset+size])
+		scalar, err := new(edwards25519.Scalar).SetBytesWithClamping(sum[:32])
+		assert.NoError(t, err)
+		blockKey, err := new(edwards25519.Point).SetBytes(raw[offset+1 : offset+33])
+		assert.NoError(t, err)
+		expected.Add(expected, new(edwards25519.Point).ScalarMult(scalar, blockKey))
+		offset += size
+	}
+	assert.Equal(t, len(raw), offset)
+
+	parsed := &License{}
+	assert.NoError(t, parsed.Unmarshal(raw))
+	key, err := parsed.Verify(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, ed25519.PublicKey(expected.Bytes()), key)
+}
@@ -0,0 +1,27 @@
+// Package licensetest provides license chains captured from real servers for
+// the tests of the packages handling licenses.
+package licensetest
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// ServerLicense is the base64 license sent by a real server in the l parameter
+// of initivexpand2, a Server block followed by an Ephemeral block
+const ServerLicense = "AQA1hUFJiiSs0wFXkYuPUJVcDa6XCrZTcsvkB0Ffzz4CmwIITRXgCqeTYAcAAAAgQW5vbnltb3VzAAC4R+5mos+UQ/KCbkpQLMI5WRp4wkQu8e5PZY4zU+/FlyAJwaE8CcJJ/A=="
+
+var (
+	// ServerLicenseTime is 2018-03-10 12:00:00 UTC, inside the window of the
+	// Ephemeral block of ServerLicense which is valid for twelve hours only
+	ServerLicenseTime = time.Unix(1520683200, 0)
+
+	// ServerEK is the key derived from ServerLicense, taken from the license test
+	// of tsclientlib which derives it with its own implementation of the chain
+	ServerEK = ed25519.PublicKey{
+		0x40, 0xe9, 0x50, 0xc4, 0x61, 0xba, 0x18, 0x3a,
+		0x1e, 0xb7, 0xcb, 0xb1, 0x9a, 0xc3, 0xd8, 0xd9,
+		0xc4, 0xd5, 0x24, 0xdb, 0x38, 0xf7, 0x2d, 0x3d,
+		0x66, 0x75, 0x77, 0x2a, 0xc5, 0x9c, 0xc5, 0xc6,
+	}
+)
@@ -1,8 +1,34 @@
 package license
 
-import "crypto/sha512"
+import (
+	"bytes"
+	"crypto/sha512"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
 
 func licenseHash(data []byte) []byte {
 	s := sha512.Sum512(data)
 	return s[:32]
 }
+
+// unmarshalString reads a null terminated string, the size includes the terminator
+func unmarshalString(raw []byte) (string, int, error) {
+	end := bytes.IndexByte(raw, 0x00)
+	if end < 0 {
+		return "", 0, tsErrors.Errorf(tsErrors.InvalidLicense, "string not terminated")
+	}
+	return string(raw[:end]), end + 1, nil
+}
+
+// unmarshalWhole runs the decoder and rejects data after the decoded part
+func unmarshalWhole(raw []byte, unmarshal func([]byte) (int, error)) error {
+	size, err := unmarshal(raw)
+	if err != nil {
+		return err
+	}
+	if size != len(raw) {
+		return tsErrors.Errorf(tsErrors.InvalidLicense, "trailing data")
+	}
+	return nil
+}
@@ -1,34 +1,97 @@
 package packets
 
-import "encoding/binary"
+import (
+	"encoding/binary"
 
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+// AckPacket acknowledges a Command packet when its type is PacketTypeAck,
+// and a CommandLow packet when its type is PacketTypeAckLow
 type AckPacket struct {
-	C2S      *C2SPacket
-	S2C      *S2CPacket
+	PacketDirection
+	C2S *C2SPacket
+	S2C *S2CPacket
+	// 02 bytes : The packet id of the acknowledged packet
 	PacketId uint16
 }
 
 func (ap AckPacket) Marshal() ([]byte, error) {
 	var (
 		header []byte
-		pid    []byte
 		err    error
 	)
 	if ap.C2S != nil {
 		header, err = ap.C2S.Marshal()
 	} else {
-		header, err = ap.C2S.Marshal()
+		header, err = ap.S2C.Marshal()
 	}
 	if err != nil {
 		return nil, err
 	}
-	binary.BigEndian.PutUint16(pid, ap.PacketId)
-	return append(header, pid...), nil
+	return append(header, byte(ap.PacketId>>8), byte(ap.PacketId)), nil
 }
 
-func (ap AckPacket) Unmarshal(bytes []byte) error {
-	//TODO implement me
-	panic("implement me")
+func (ap *AckPacket) Unmarshal(raw []byte) error {
+	var (
+		dataOffset int
+		t          PacketType
+		err        error
+	)
+
+	if ap.Direction() == PacketDirectionC2S {
+		if len(raw) < C2SHeaderSize+2 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 15")
+		}
+		dataOffset = C2SHeaderSize
+		ap.C2S = &C2SPacket{}
+		err = ap.C2S.Unmarshal(raw)
+		t = ap.C2S.PacketType
+	} else {
+		if len(raw) < S2CHeaderSize+2 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 13")
+		}
+		dataOffset = S2CHeaderSize
+		ap.S2C = &S2CPacket{}
+		err = ap.S2C.Unmarshal(raw)
+		t = ap.S2C.PacketType
+	}
+	if err != nil {
+		return err
+	}
+	if t != PacketTypeAck && t != PacketTypeAckLow {
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, t, PacketTypeAck)
+	}
+
+	ap.PacketId = binary.BigEndian.Uint16(raw[dataOffset : dataOffset+2])
+	return nil
 }
 
 func (ap AckPacket) isPacket() {}
+
+// AckedType returns the packet type acknowledged by this ack
+func (ap AckPacket) AckedType() PacketType {
+	t := PacketTypeAck
+	if ap.C2S != nil {
+		t = ap.C2S.PacketType
+	} else if ap.S2C != nil {
+		t = ap.S2C.PacketType
+	}
+	if t == PacketTypeAckLow {
+		return PacketTypeCommandLow
+	}
+	return PacketTypeCommand
+}
+
+// AckTypeFor returns the ack packet type paired with a reliable packet type,
+// Command is acknowledged by Ack and CommandLow by AckLow
+func AckTypeFor(t PacketType) (PacketType, bool) {
+	switch t {
+	case PacketTypeCommand:
+		return PacketTypeAck, true
+	case PacketTypeCommandLow:
+		return PacketTypeAckLow, true
+	default:
+		return 0, false
+	}
+}
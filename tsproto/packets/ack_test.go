@@ -0,0 +1,58 @@
+package packets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckPacket(t *testing.T) {
+	for _, c := range []struct {
+		commandType PacketType
+		ackType     PacketType
+	}{
+		{PacketTypeCommand, PacketTypeAck},
+		{PacketTypeCommandLow, PacketTypeAckLow},
+	} {
+		ackType, ok := AckTypeFor(c.commandType)
+		assert.True(t, ok)
+		assert.Equal(t, c.ackType, ackType)
+
+		// S2C only, the C2S header must not be touched
+		ack := AckPacket{
+			S2C:      &S2CPacket{MAC: "12345678", PacketId: 1, PacketType: ackType},
+			PacketId: 0x1234,
+		}
+		raw, err := ack.Marshal()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x12, 0x34}, raw[S2CHeaderSize:])
+
+		p, err := Decode(raw, PacketDirectionS2C)
+		assert.NoError(t, err)
+		decoded := p.(*AckPacket)
+		assert.Equal(t, uint16(0x1234), decoded.PacketId)
+		assert.Equal(t, c.commandType, decoded.AckedType())
+
+		ack = AckPacket{
+			C2S:      &C2SPacket{MAC: "12345678", PacketId: 2, ClientId: 5, PacketType: ackType},
+			PacketId: 0xfffe,
+		}
+		raw, err = ack.Marshal()
+		assert.NoError(t, err)
+		p, err = Decode(raw, PacketDirectionC2S)
+		assert.NoError(t, err)
+		decoded = p.(*AckPacket)
+		assert.Equal(t, *ack.C2S, *decoded.C2S)
+		assert.Equal(t, uint16(0xfffe), decoded.PacketId)
+		assert.Equal(t, c.commandType, decoded.AckedType())
+
+		_, err = Decode(raw[:C2SHeaderSize+1], PacketDirectionC2S)
+		assert.Error(t, err)
+	}
+
+	_, ok := AckTypeFor(PacketTypeVoice)
+	assert.False(t, ok)
+
+	raw, _ := AckPacket{S2C: &S2CPacket{PacketType: PacketTypePing}}.Marshal()
+	assert.Error(t, (&AckPacket{PacketDirection: PacketDirectionS2C}).Unmarshal(raw))
+}
@@ -2,10 +2,10 @@ package packets
 
 import (
 	"strings"
-
-	"github.com/pkg/errors"
+	"unicode/utf8"
 
 	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/quicklz"
 )
 
 type CommandPacket struct {
@@ -13,6 +13,9 @@ type CommandPacket struct {
 	C2S     *C2SPacket
 	S2C     *S2CPacket
 	Command *Command
+	// Data is the raw payload, it is used instead of Command when Command is nil,
+	// e.g. for fragments and compressed payload
+	Data []byte
 }
 
 func (cp CommandPacket) Marshal() ([]byte, error) {
@@ -33,26 +36,56 @@ func (cp CommandPacket) Marshal() ([]byte, error) {
 	}
 
 	// body
-	body, err = cp.Command.Marshal()
-	if err != nil {
-		return nil, err
+	if cp.Command != nil {
+		body, err = cp.Command.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if cp.compressed() {
+			body = quicklz.Compress(body)
+		}
+	} else {
+		body = cp.Data
 	}
 
 	return append(header, body...), nil
 }
 
+// Unmarshal parses the packet header and payload, the Command is only parsed when
+// the payload is not fragmented, compressed payload is decompressed before parsing
 func (cp *CommandPacket) Unmarshal(bytes []byte) error {
+	err := cp.UnmarshalHeader(bytes)
+	if err != nil {
+		return err
+	}
+
+	cp.Command = nil
+	if cp.fragmented() {
+		return nil
+	}
+
+	cp.Command, err = decodeCommand(cp.Data, cp.compressed())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnmarshalHeader parses the packet header and keeps the payload in Data without
+// parsing it, it is used for the fragments in the middle which carry no flag
+func (cp *CommandPacket) UnmarshalHeader(bytes []byte) error {
 	var (
 		dataOffset int
 		err        error
 	)
 
 	if cp.Direction() == PacketDirectionC2S {
-		dataOffset = 13
+		dataOffset = C2SHeaderSize
 		cp.C2S = &C2SPacket{}
 		err = cp.C2S.Unmarshal(bytes)
 	} else {
-		dataOffset = 11
+		dataOffset = S2CHeaderSize
 		cp.S2C = &S2CPacket{}
 		err = cp.S2C.Unmarshal(bytes)
 	}
@@ -61,17 +94,51 @@ func (cp *CommandPacket) Unmarshal(bytes []byte) error {
 		return err
 	}
 
-	cp.Command = &Command{}
-	err = cp.Command.Unmarshal(bytes[dataOffset:])
-	if err != nil {
-		return err
+	cp.Data = append([]byte{}, bytes[dataOffset:]...)
+	return nil
+}
+
+func (cp CommandPacket) packetId() uint16 {
+	if cp.C2S != nil {
+		return cp.C2S.PacketId
 	}
+	return cp.S2C.PacketId
+}
 
-	return nil
+func (cp CommandPacket) fragmented() bool {
+	if cp.C2S != nil {
+		return cp.C2S.Fragmented
+	}
+	return cp.S2C.Fragmented
+}
+
+func (cp CommandPacket) compressed() bool {
+	if cp.C2S != nil {
+		return cp.C2S.Compressed
+	}
+	return cp.S2C.Compressed
 }
 
 func (cp CommandPacket) isPacket() {}
 
+// decodeCommand parses the command from payload, decompress it first if compressed
+func decodeCommand(data []byte, compressed bool) (*Command, error) {
+	var err error
+	if compressed {
+		data, err = quicklz.Decompress(data, MaxCommandSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := &Command{}
+	err = cmd.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
 type Command struct {
 	Name   string
 	Params CommandParams
@@ -103,15 +170,28 @@ func (c Command) Marshal() ([]byte, error) {
 }
 
 func (c *Command) Unmarshal(raw []byte) error {
+	if len(raw) <= 0 {
+		return tsErrors.Errorf(tsErrors.InvalidCommand, "empty command payload")
+	}
+	if !utf8.Valid(raw) {
+		return tsErrors.Errorf(tsErrors.InvalidCommand, "payload is not valid utf-8")
+	}
+
 	part := strings.Split(string(raw), " ")
-	if len(part) <= 0 {
-		return errors.Errorf(tsErrors.InvalidCommand, "empty command payload")
+	if part[0] == "" {
+		return tsErrors.Errorf(tsErrors.InvalidCommand, "empty command name")
 	}
 
 	c.Name = part[0]
 	c.Params = make(map[string]string)
 	for i := 1; i < len(part); i++ {
+		if part[i] == "" {
+			continue
+		}
 		paramKV := strings.SplitN(part[i], "=", 2)
+		if paramKV[0] == "" {
+			return tsErrors.Errorf(tsErrors.InvalidCommand, "empty parameter name")
+		}
 		if len(paramKV) == 2 {
 			val := strings.NewReplacer(
 				"\\v", "\u000b",
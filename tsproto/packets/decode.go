@@ -0,0 +1,184 @@
+package packets
+
+import (
+	"sync"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+// PacketFactory creates an empty packet of the concrete type for a raw datagram,
+// the returned packet will be filled by its Unmarshal afterwards
+type PacketFactory func(dir PacketDirection, raw []byte) (Packet, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[PacketType]PacketFactory)
+)
+
+func init() {
+	Register(PacketTypeVoice, newVoicePacket)
+	Register(PacketTypeVoiceWhisper, newVoiceWhisperPacket)
+	Register(PacketTypeCommand, newCommandPacket)
+	Register(PacketTypeCommandLow, newCommandPacket)
+	Register(PacketTypePing, newPingPacket)
+	Register(PacketTypePong, newPongPacket)
+	Register(PacketTypeAck, newAckPacket)
+	Register(PacketTypeAckLow, newAckPacket)
+	Register(PacketTypeInit1, newInitPacket)
+}
+
+// Register binds a packet type to the factory used by Decode,
+// it replaces the factory registered before for the same packet type
+func Register(t PacketType, factory PacketFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[t] = factory
+}
+
+// Decode inspects the packet header of a raw datagram and unmarshal it
+// into the concrete packet registered for its packet type
+func Decode(raw []byte, dir PacketDirection) (Packet, error) {
+	var (
+		t   PacketType
+		err error
+	)
+	if dir == PacketDirectionC2S {
+		header := C2SPacket{}
+		err = header.Unmarshal(raw)
+		t = header.PacketType
+	} else {
+		header := S2CPacket{}
+		err = header.Unmarshal(raw)
+		t = header.PacketType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[t]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, tsErrors.Errorf(tsErrors.UnknownPacketType, t)
+	}
+
+	p, err := factory(dir, raw)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Unmarshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newCommandPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &CommandPacket{PacketDirection: dir}, nil
+}
+
+func newVoicePacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &VoicePacket{PacketDirection: dir}, nil
+}
+
+func newVoiceWhisperPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &VoiceWhisperPacket{PacketDirection: dir}, nil
+}
+
+func newPingPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &PingPacket{PacketDirection: dir}, nil
+}
+
+func newPongPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &PongPacket{PacketDirection: dir}, nil
+}
+
+func newAckPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &AckPacket{PacketDirection: dir}, nil
+}
+
+func newRawPacket(dir PacketDirection, _ []byte) (Packet, error) {
+	return &RawPacket{PacketDirection: dir}, nil
+}
+
+// newInitPacket selects the low-level init packet by its stage byte
+func newInitPacket(dir PacketDirection, raw []byte) (Packet, error) {
+	if dir == PacketDirectionC2S {
+		// 4 bytes version timestamp precede the stage
+		if len(raw) < C2SHeaderSize+5 {
+			return nil, tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 18")
+		}
+		switch stage := raw[C2SHeaderSize+4]; stage {
+		case 0:
+			return &Init0Packet{}, nil
+		case 2:
+			return &Init2Packet{}, nil
+		case 4:
+			return &Init4Packet{}, nil
+		default:
+			return nil, tsErrors.Errorf(tsErrors.UnknownLowInitStage, stage)
+		}
+	}
+
+	if len(raw) < S2CHeaderSize+1 {
+		return nil, tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 12")
+	}
+	switch stage := raw[S2CHeaderSize]; stage {
+	case 1:
+		return &Init1Packet{}, nil
+	case 3:
+		return &Init3Packet{}, nil
+	default:
+		return nil, tsErrors.Errorf(tsErrors.UnknownLowInitStage, stage)
+	}
+}
+
+// RawPacket keeps the packet body as-is, it is used for the packet types
+// without a dedicated codec
+type RawPacket struct {
+	PacketDirection
+	C2S  *C2SPacket
+	S2C  *S2CPacket
+	Data []byte
+}
+
+func (rp RawPacket) Marshal() ([]byte, error) {
+	var (
+		header []byte
+		err    error
+	)
+	if rp.C2S != nil {
+		header, err = rp.C2S.Marshal()
+	} else {
+		header, err = rp.S2C.Marshal()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(header, rp.Data...), nil
+}
+
+func (rp *RawPacket) Unmarshal(raw []byte) error {
+	var (
+		dataOffset int
+		err        error
+	)
+
+	if rp.Direction() == PacketDirectionC2S {
+		dataOffset = C2SHeaderSize
+		rp.C2S = &C2SPacket{}
+		err = rp.C2S.Unmarshal(raw)
+	} else {
+		dataOffset = S2CHeaderSize
+		rp.S2C = &S2CPacket{}
+		err = rp.S2C.Unmarshal(raw)
+	}
+	if err != nil {
+		return err
+	}
+
+	rp.Data = append([]byte{}, raw[dataOffset:]...)
+	return nil
+}
+
+func (rp RawPacket) isPacket() {}
@@ -0,0 +1,83 @@
+package packets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeLowInit(t *testing.T) {
+	cases := []struct {
+		dir    PacketDirection
+		packet Marshaler
+		expect interface{}
+	}{
+		{PacketDirectionC2S, Init0Packet{}, &Init0Packet{}},
+		{PacketDirectionS2C, Init1Packet{}, &Init1Packet{}},
+		{PacketDirectionC2S, Init2Packet{}, &Init2Packet{}},
+		{PacketDirectionS2C, Init3Packet{}, &Init3Packet{}},
+		{PacketDirectionC2S, Init4Packet{Data: []byte("clientinitiv")}, &Init4Packet{}},
+	}
+	for _, c := range cases {
+		raw, err := c.packet.Marshal()
+		assert.NoError(t, err)
+		p, err := Decode(raw, c.dir)
+		assert.NoError(t, err)
+		assert.IsType(t, c.expect, p)
+	}
+}
+
+func TestDecodeCommand(t *testing.T) {
+	cp := CommandPacket{
+		C2S: &C2SPacket{
+			MAC:        "12345678",
+			PacketId:   3,
+			ClientId:   1,
+			PacketType: PacketTypeCommandLow,
+		},
+		Command: &Command{Name: "clientinit", Params: CommandParams{"client_nickname": "bot"}},
+	}
+	raw, err := cp.Marshal()
+	assert.NoError(t, err)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	decoded, ok := p.(*CommandPacket)
+	assert.True(t, ok)
+	assert.Equal(t, *cp.C2S, *decoded.C2S)
+	assert.Equal(t, *cp.Command, *decoded.Command)
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	raw, _ := S2CPacket{PacketType: 15}.Marshal()
+	_, err := Decode(raw, PacketDirectionS2C)
+	assert.Error(t, err)
+
+	_, err = Decode(raw[:5], PacketDirectionS2C)
+	assert.Error(t, err)
+
+	raw, _ = S2CPacket{PacketType: PacketTypeInit1}.Marshal()
+	_, err = Decode(append(raw, 0x02), PacketDirectionS2C)
+	assert.Error(t, err)
+}
+
+type customPacket struct {
+	RawPacket
+}
+
+func TestDecodeRegister(t *testing.T) {
+	registryMu.RLock()
+	origin := registry[PacketTypePing]
+	registryMu.RUnlock()
+	defer Register(PacketTypePing, origin)
+
+	Register(PacketTypePing, func(dir PacketDirection, _ []byte) (Packet, error) {
+		return &customPacket{RawPacket{PacketDirection: dir}}, nil
+	})
+
+	raw, _ := S2CPacket{PacketType: PacketTypePing}.Marshal()
+	p, err := Decode(append(raw, 0x01, 0x02), PacketDirectionS2C)
+	assert.NoError(t, err)
+	assert.IsType(t, &customPacket{}, p)
+	assert.Equal(t, []byte{0x01, 0x02}, p.(*customPacket).Data)
+}
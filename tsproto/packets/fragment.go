@@ -0,0 +1,140 @@
+package packets
+
+import (
+	"bytes"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/quicklz"
+)
+
+const (
+	// MaxPacketSize is the maximum size of a whole packet including header
+	MaxPacketSize = 500
+	// MaxCommandSize limits the payload size of a reassembled command
+	MaxCommandSize = 1 << 20
+)
+
+// Fragment splits a command packet into packets fitting in MaxPacketSize.
+// The first and the last fragment are flagged as Fragmented and only the first one
+// keeps the Compressed flag, the packet ids are counted up from the packet id of cp.
+// The Command is compressed before splitting when cp is flagged as Compressed.
+// A command fitting in one packet is returned as a single packet.
+func Fragment(cp CommandPacket) ([]*CommandPacket, error) {
+	var (
+		data       = cp.Data
+		headerSize = S2CHeaderSize
+		err        error
+	)
+	if cp.Command != nil {
+		data, err = cp.Command.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if cp.compressed() {
+			data = quicklz.Compress(data)
+		}
+	}
+	if cp.C2S != nil {
+		headerSize = C2SHeaderSize
+	}
+
+	chunkSize := MaxPacketSize - headerSize
+	if len(data) <= chunkSize {
+		single := cp
+		single.Command = nil
+		single.Data = data
+		return []*CommandPacket{&single}, nil
+	}
+
+	var (
+		fragments  []*CommandPacket
+		basePid    = cp.packetId()
+		compressed = cp.compressed()
+	)
+	for offset := 0; offset < len(data); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		first, last := offset == 0, end == len(data)
+
+		fragment := &CommandPacket{
+			PacketDirection: cp.PacketDirection,
+			Data:            data[offset:end],
+		}
+		pid := basePid + uint16(len(fragments))
+		if cp.C2S != nil {
+			header := *cp.C2S
+			header.PacketId = pid
+			header.Fragmented = first || last
+			header.Compressed = first && compressed
+			fragment.C2S = &header
+		} else {
+			header := *cp.S2C
+			header.PacketId = pid
+			header.Fragmented = first || last
+			header.Compressed = first && compressed
+			fragment.S2C = &header
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments, nil
+}
+
+// Reassembler joins the fragments of one command channel, Command and CommandLow
+// need their own Reassembler. Packets must be pushed in packet id order, e.g. as
+// released by a receive window, fragments in the middle carry no flag and should be
+// parsed with CommandPacket.UnmarshalHeader.
+type Reassembler struct {
+	fragments  [][]byte
+	nextId     uint16
+	compressed bool
+	size       int
+}
+
+// Push feeds a command packet, it returns the complete command once the last fragment
+// arrives and nil while waiting for more fragments
+func (r *Reassembler) Push(cp *CommandPacket) (*Command, error) {
+	pid := cp.packetId()
+
+	// not in a fragment sequence
+	if r.fragments == nil {
+		if !cp.fragmented() {
+			return decodeCommand(cp.Data, cp.compressed())
+		}
+		r.fragments = [][]byte{cp.Data}
+		r.nextId = pid + 1
+		r.compressed = cp.compressed()
+		r.size = len(cp.Data)
+		return nil, nil
+	}
+
+	if pid != r.nextId {
+		expect := r.nextId
+		r.Reset()
+		return nil, tsErrors.Errorf(tsErrors.FragmentDisorder, pid, expect)
+	}
+	r.size += len(cp.Data)
+	if r.size > MaxCommandSize {
+		size := r.size
+		r.Reset()
+		return nil, tsErrors.Errorf(tsErrors.CommandTooLarge, size, MaxCommandSize)
+	}
+	r.fragments = append(r.fragments, cp.Data)
+	r.nextId++
+
+	if !cp.fragmented() {
+		return nil, nil
+	}
+
+	data, compressed := bytes.Join(r.fragments, []byte{}), r.compressed
+	r.Reset()
+	return decodeCommand(data, compressed)
+}
+
+// Reset drops the buffered fragments
+func (r *Reassembler) Reset() {
+	r.fragments = nil
+	r.compressed = false
+	r.size = 0
+}
@@ -0,0 +1,139 @@
+package packets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func bigCommand() *Command {
+	return &Command{
+		Name: "initserver",
+		Params: CommandParams{
+			"virtualserver_name":           "TeamSpeak ]I[ Server",
+			"virtualserver_welcomemessage": strings.Repeat("Welcome to TeamSpeak, check www.teamspeak.com for latest information ", 20),
+		},
+	}
+}
+
+func TestFragmentReassemble(t *testing.T) {
+	for _, dir := range []PacketDirection{PacketDirectionC2S, PacketDirectionS2C} {
+		cp := CommandPacket{PacketDirection: dir, Command: bigCommand()}
+		if dir == PacketDirectionC2S {
+			cp.C2S = &C2SPacket{PacketId: 65534, ClientId: 1, PacketType: PacketTypeCommand}
+		} else {
+			cp.S2C = &S2CPacket{PacketId: 65534, PacketType: PacketTypeCommand}
+		}
+
+		fragments, err := Fragment(cp)
+		assert.NoError(t, err)
+		assert.Greater(t, len(fragments), 2)
+
+		r := &Reassembler{}
+		for i, fragment := range fragments {
+			first, last := i == 0, i == len(fragments)-1
+			assert.Equal(t, first || last, fragment.fragmented())
+			assert.Equal(t, uint16(65534+i), fragment.packetId())
+
+			raw, err := fragment.Marshal()
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(raw), MaxPacketSize)
+
+			received := &CommandPacket{PacketDirection: dir}
+			assert.NoError(t, received.UnmarshalHeader(raw))
+			cmd, err := r.Push(received)
+			assert.NoError(t, err)
+			if last {
+				assert.Equal(t, cp.Command, cmd)
+			} else {
+				assert.Nil(t, cmd)
+			}
+		}
+	}
+}
+
+func TestFragmentSingle(t *testing.T) {
+	cp := CommandPacket{
+		S2C:     &S2CPacket{PacketId: 7, PacketType: PacketTypeCommand},
+		Command: &Command{Name: "clientinitiv", Params: CommandParams{"ot": "1"}},
+	}
+	fragments, err := Fragment(cp)
+	assert.NoError(t, err)
+	assert.Len(t, fragments, 1)
+	assert.False(t, fragments[0].fragmented())
+
+	raw, err := fragments[0].Marshal()
+	assert.NoError(t, err)
+	received := &CommandPacket{PacketDirection: PacketDirectionS2C}
+	assert.NoError(t, received.Unmarshal(raw))
+	cmd, err := (&Reassembler{}).Push(received)
+	assert.NoError(t, err)
+	assert.Equal(t, cp.Command, cmd)
+}
+
+func TestReassembleDisorder(t *testing.T) {
+	fragments, err := Fragment(CommandPacket{
+		S2C:     &S2CPacket{PacketType: PacketTypeCommand},
+		Command: bigCommand(),
+	})
+	assert.NoError(t, err)
+
+	r := &Reassembler{}
+	_, err = r.Push(fragments[0])
+	assert.NoError(t, err)
+	_, err = r.Push(fragments[2])
+	assert.True(t, errors.Is(err, tsErrors.ErrFragmentDisorder))
+
+	// a new sequence can start after the disorder
+	for i, fragment := range fragments {
+		cmd, err := r.Push(fragment)
+		assert.NoError(t, err)
+		assert.Equal(t, i == len(fragments)-1, cmd != nil)
+	}
+}
+
+func TestFragmentCompressed(t *testing.T) {
+	cmd := &Command{Name: "channellist", Params: CommandParams{}}
+	for i := 0; i < 200; i++ {
+		cmd.Params["channel_name"+strings.Repeat("x", i%20)+string(rune('a'+i%26))] = "Default\\sChannel"
+	}
+	cp := CommandPacket{
+		S2C:     &S2CPacket{PacketType: PacketTypeCommand, Compressed: true},
+		Command: cmd,
+	}
+	fragments, err := Fragment(cp)
+	assert.NoError(t, err)
+	assert.Greater(t, len(fragments), 1)
+	for i, fragment := range fragments {
+		assert.Equal(t, i == 0, fragment.compressed())
+	}
+
+	r := &Reassembler{}
+	var result *Command
+	for _, fragment := range fragments {
+		raw, err := fragment.Marshal()
+		assert.NoError(t, err)
+		received := &CommandPacket{PacketDirection: PacketDirectionS2C}
+		assert.NoError(t, received.UnmarshalHeader(raw))
+		result, err = r.Push(received)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, cmd, result)
+}
+
+func TestCommandPacketCompressed(t *testing.T) {
+	cp := CommandPacket{
+		C2S:     &C2SPacket{PacketType: PacketTypeCommand, Compressed: true},
+		Command: &Command{Name: "clientinit", Params: CommandParams{"client_nickname": "bot"}},
+	}
+	raw, err := cp.Marshal()
+	assert.NoError(t, err)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	assert.Equal(t, cp.Command, p.(*CommandPacket).Command)
+}
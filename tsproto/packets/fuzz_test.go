@@ -0,0 +1,96 @@
+package packets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func TestTypedErrors(t *testing.T) {
+	err := (&C2SPacket{}).Unmarshal([]byte{0x01})
+	assert.True(t, errors.Is(err, tsErrors.ErrPacketIncomplete))
+	assert.False(t, errors.Is(err, tsErrors.ErrInvalidCommand))
+
+	raw, _ := Init0Packet{}.Marshal()
+	err = (&Init2Packet{}).Unmarshal(raw)
+	assert.True(t, errors.Is(err, tsErrors.ErrPacketIncomplete))
+
+	raw, _ = Init2Packet{}.Marshal()
+	raw[12] = byte(PacketTypeCommand)
+	err = (&Init2Packet{}).Unmarshal(raw)
+	assert.True(t, errors.Is(err, tsErrors.ErrPacketTypeUnmatched))
+
+	for _, payload := range []string{"", " a=b", "cmd =b", "cmd \xff"} {
+		err = (&Command{}).Unmarshal([]byte(payload))
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidCommand), payload)
+	}
+}
+
+func addPacketSeeds(f *testing.F) {
+	for _, p := range []Marshaler{
+		Init0Packet{},
+		Init1Packet{},
+		Init2Packet{},
+		Init3Packet{},
+		Init4Packet{Data: []byte("clientinitiv alpha=AAAA")},
+		CommandPacket{
+			S2C:     &S2CPacket{PacketType: PacketTypeCommand},
+			Command: &Command{Name: "initserver", Params: CommandParams{"virtualserver_name": "TeamSpeak ]I[ Server"}},
+		},
+	} {
+		raw, _ := p.Marshal()
+		f.Add(raw)
+	}
+	f.Add([]byte{})
+}
+
+func FuzzDecode(f *testing.F) {
+	addPacketSeeds(f)
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		for _, dir := range []PacketDirection{PacketDirectionC2S, PacketDirectionS2C} {
+			p, err := Decode(raw, dir)
+			if err != nil {
+				continue
+			}
+			if _, err = p.Marshal(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func FuzzLowInitUnmarshal(f *testing.F) {
+	addPacketSeeds(f)
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		for _, p := range []Unmarshaler{
+			&Init0Packet{},
+			&Init1Packet{},
+			&Init2Packet{},
+			&Init3Packet{},
+			&Init4Packet{},
+		} {
+			_ = p.Unmarshal(raw)
+		}
+	})
+}
+
+func FuzzCommandUnmarshal(f *testing.F) {
+	f.Add([]byte("clientinit client_nickname=bot client_version=3.?.?\\s[Build:\\s5680278000]"))
+	f.Add([]byte("clientek ek=AAAA proof=BBBB"))
+	f.Add([]byte("a =b"))
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		c := &Command{}
+		if err := c.Unmarshal(raw); err != nil {
+			return
+		}
+		if c.Name == "" {
+			t.Fatal("empty command name accepted")
+		}
+		if _, err := c.Marshal(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
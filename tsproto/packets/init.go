@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 
-	"github.com/pkg/errors"
-
 	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 )
 
@@ -25,13 +23,28 @@ type Init0Packet struct {
 }
 
 func (p Init0Packet) Marshal() ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	if p.C2SPacket.MAC == "" {
+		FillLowInitPacketHeader(&p.C2SPacket)
+	}
+
+	data, err := p.C2SPacket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	versionByte := make([]byte, 4)
+	binary.BigEndian.PutUint32(versionByte, p.VersionTimestamp-VersionTimestampDifference)
+	timestampByte := make([]byte, 4)
+	binary.BigEndian.PutUint32(timestampByte, p.Timestamp)
+
+	// 8 bytes reserved zeros at the end
+	data = bytes.Join([][]byte{data, versionByte, {0x00}, timestampByte, p.Random0[0:], make([]byte, 8)}, []byte{})
+	return data, nil
 }
 
 func (p *Init0Packet) Unmarshal(raw []byte) error {
 	if len(raw) != 34 {
-		return errors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 34")
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 34")
 	}
 
 	// parse packet header
@@ -40,13 +53,13 @@ func (p *Init0Packet) Unmarshal(raw []byte) error {
 		return err
 	}
 	if p.PacketType != 8 {
-		return errors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
 	}
 
 	// read data
 	data := raw[13:34]
 	if data[4] != 0 {
-		return errors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 0)
+		return tsErrors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 0)
 	}
 	p.VersionTimestamp = binary.BigEndian.Uint32(data[0:4]) + VersionTimestampDifference
 	p.Timestamp = binary.BigEndian.Uint32(data[5:9])
@@ -77,9 +90,28 @@ func (p Init1Packet) Marshal() ([]byte, error) {
 	return data, nil
 }
 
-func (p *Init1Packet) Unmarshal(pkg []byte) error {
-	//TODO implement me
-	panic("implement me")
+func (p *Init1Packet) Unmarshal(raw []byte) error {
+	if len(raw) != 32 {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 32")
+	}
+
+	// parse packet header
+	err := p.S2CPacket.Unmarshal(raw)
+	if err != nil {
+		return err
+	}
+	if p.PacketType != 8 {
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
+	}
+
+	// read data
+	data := raw[11:32]
+	if data[0] != 1 {
+		return tsErrors.Errorf(tsErrors.PacketLowInitDisorder, data[0], 1)
+	}
+	p.Random1 = *(*[16]byte)(data[1:17])
+	p.Random0 = [4]byte{data[20], data[19], data[18], data[17]}
+	return nil
 }
 
 type Init2Packet struct {
@@ -94,13 +126,25 @@ type Init2Packet struct {
 }
 
 func (p Init2Packet) Marshal() ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	if p.C2SPacket.MAC == "" {
+		FillLowInitPacketHeader(&p.C2SPacket)
+	}
+
+	data, err := p.C2SPacket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	versionByte := make([]byte, 4)
+	binary.BigEndian.PutUint32(versionByte, p.VersionTimestamp-VersionTimestampDifference)
+
+	data = bytes.Join([][]byte{data, versionByte, {0x02}, p.Random1[0:], {p.Random0[3], p.Random0[2], p.Random0[1], p.Random0[0]}}, []byte{})
+	return data, nil
 }
 
 func (p *Init2Packet) Unmarshal(raw []byte) error {
 	if len(raw) != 38 {
-		return errors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 38")
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 38")
 	}
 
 	// parse packet header
@@ -109,17 +153,17 @@ func (p *Init2Packet) Unmarshal(raw []byte) error {
 		return err
 	}
 	if p.PacketType != 8 {
-		return errors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
 	}
 
 	// read data
 	data := raw[13:38]
 	if data[4] != 2 {
-		return errors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 2)
+		return tsErrors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 2)
 	}
 	p.VersionTimestamp = binary.BigEndian.Uint32(data[0:4]) + VersionTimestampDifference
 	p.Random1 = *(*[16]byte)(data[5:21])
-	p.Random0 = *(*[4]byte)(data[21:25])
+	p.Random0 = [4]byte{data[24], data[23], data[22], data[21]}
 
 	return nil
 }
@@ -153,9 +197,30 @@ func (p Init3Packet) Marshal() ([]byte, error) {
 	return data, nil
 }
 
-func (p *Init3Packet) Unmarshal(pkg []byte) error {
-	//TODO implement me
-	panic("implement me")
+func (p *Init3Packet) Unmarshal(raw []byte) error {
+	if len(raw) != 244 {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), "= 244")
+	}
+
+	// parse packet header
+	err := p.S2CPacket.Unmarshal(raw)
+	if err != nil {
+		return err
+	}
+	if p.PacketType != 8 {
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
+	}
+
+	// read data
+	data := raw[11:244]
+	if data[0] != 3 {
+		return tsErrors.Errorf(tsErrors.PacketLowInitDisorder, data[0], 3)
+	}
+	p.X = *(*[64]byte)(data[1:65])
+	p.N = *(*[64]byte)(data[65:129])
+	p.Level = binary.BigEndian.Uint32(data[129:133])
+	p.Random2 = *(*[100]byte)(data[133:233])
+	return nil
 }
 
 type Init4Packet struct {
@@ -180,13 +245,27 @@ type Init4Packet struct {
 }
 
 func (p Init4Packet) Marshal() ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	if p.C2SPacket.MAC == "" {
+		FillLowInitPacketHeader(&p.C2SPacket)
+	}
+
+	data, err := p.C2SPacket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	versionByte := make([]byte, 4)
+	binary.BigEndian.PutUint32(versionByte, p.VersionTimestamp-VersionTimestampDifference)
+	levelByte := make([]byte, 4)
+	binary.BigEndian.PutUint32(levelByte, p.Level)
+
+	data = bytes.Join([][]byte{data, versionByte, {0x04}, p.X[0:], p.N[0:], levelByte, p.Random2[0:], p.Y[0:], p.Data}, []byte{})
+	return data, nil
 }
 
 func (p *Init4Packet) Unmarshal(raw []byte) error {
 	if len(raw) <= 314 {
-		return errors.Errorf(tsErrors.PacketIncomplete, len(raw), "> 314")
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), "> 314")
 	}
 
 	// parse packet header
@@ -195,13 +274,13 @@ func (p *Init4Packet) Unmarshal(raw []byte) error {
 		return err
 	}
 	if p.PacketType != 8 {
-		return errors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
+		return tsErrors.Errorf(tsErrors.PacketTypeUnmatched, p.PacketType, 8)
 	}
 
 	// read data
 	data := raw[13:]
 	if data[4] != 4 {
-		return errors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 4)
+		return tsErrors.Errorf(tsErrors.PacketLowInitDisorder, data[4], 4)
 	}
 	p.VersionTimestamp = binary.BigEndian.Uint32(data[0:4]) + VersionTimestampDifference
 	p.X = *(*[64]byte)(data[5:69])
@@ -214,6 +293,12 @@ func (p *Init4Packet) Unmarshal(raw []byte) error {
 	return nil
 }
 
+func (p Init0Packet) isPacket() {}
+func (p Init1Packet) isPacket() {}
+func (p Init2Packet) isPacket() {}
+func (p Init3Packet) isPacket() {}
+func (p Init4Packet) isPacket() {}
+
 // FillLowInitPacketHeader for filling init series packets' consistent
 // packet header structure
 func FillLowInitPacketHeader(packet interface{}) {
@@ -0,0 +1,117 @@
+package packets
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowInitExchange(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	// client -> server: Init0
+	init0 := Init0Packet{
+		VersionTimestamp: 1661848003,
+		Timestamp:        1662000000,
+		Random0:          [4]byte{0x01, 0x02, 0x03, 0x04},
+	}
+	raw, err := init0.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 34)
+	assert.Equal(t, []byte("TS3INIT1"), raw[0:8])
+	assert.Equal(t, byte(0x88), raw[12])
+
+	serverInit0 := &Init0Packet{}
+	assert.NoError(t, serverInit0.Unmarshal(raw))
+	assert.Equal(t, init0.VersionTimestamp, serverInit0.VersionTimestamp)
+	assert.Equal(t, init0.Timestamp, serverInit0.Timestamp)
+	assert.Equal(t, init0.Random0, serverInit0.Random0)
+
+	// server -> client: Init1
+	init1 := Init1Packet{Random0: serverInit0.Random0}
+	r.Read(init1.Random1[:])
+	raw, err = init1.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 32)
+	// A0 is reversed on the wire
+	assert.Equal(t, []byte{0x04, 0x03, 0x02, 0x01}, raw[28:32])
+
+	clientInit1 := &Init1Packet{}
+	assert.NoError(t, clientInit1.Unmarshal(raw))
+	assert.Equal(t, init0.Random0, clientInit1.Random0)
+	assert.Equal(t, init1.Random1, clientInit1.Random1)
+
+	// client -> server: Init2
+	init2 := Init2Packet{
+		VersionTimestamp: init0.VersionTimestamp,
+		Random0:          clientInit1.Random0,
+		Random1:          clientInit1.Random1,
+	}
+	raw, err = init2.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 38)
+
+	serverInit2 := &Init2Packet{}
+	assert.NoError(t, serverInit2.Unmarshal(raw))
+	assert.Equal(t, init2.VersionTimestamp, serverInit2.VersionTimestamp)
+	assert.Equal(t, init0.Random0, serverInit2.Random0)
+	assert.Equal(t, init1.Random1, serverInit2.Random1)
+
+	// server -> client: Init3
+	init3 := Init3Packet{Level: 10000}
+	r.Read(init3.X[:])
+	r.Read(init3.N[:])
+	r.Read(init3.Random2[:])
+	raw, err = init3.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 244)
+
+	clientInit3 := &Init3Packet{}
+	assert.NoError(t, clientInit3.Unmarshal(raw))
+	assert.Equal(t, init3.X, clientInit3.X)
+	assert.Equal(t, init3.N, clientInit3.N)
+	assert.Equal(t, init3.Level, clientInit3.Level)
+	assert.Equal(t, init3.Random2, clientInit3.Random2)
+
+	// client -> server: Init4
+	init4 := Init4Packet{
+		VersionTimestamp: init0.VersionTimestamp,
+		X:                clientInit3.X,
+		N:                clientInit3.N,
+		Level:            clientInit3.Level,
+		Random2:          clientInit3.Random2,
+		Data:             []byte("clientinitiv alpha=AAAA ot=1"),
+	}
+	r.Read(init4.Y[:])
+	raw, err = init4.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 314+len(init4.Data))
+
+	serverInit4 := &Init4Packet{}
+	assert.NoError(t, serverInit4.Unmarshal(raw))
+	assert.Equal(t, init4.VersionTimestamp, serverInit4.VersionTimestamp)
+	assert.Equal(t, init4.X, serverInit4.X)
+	assert.Equal(t, init4.N, serverInit4.N)
+	assert.Equal(t, init4.Level, serverInit4.Level)
+	assert.Equal(t, init4.Random2, serverInit4.Random2)
+	assert.Equal(t, init4.Y, serverInit4.Y)
+	assert.Equal(t, init4.Data, serverInit4.Data)
+}
+
+func TestLowInitDisorder(t *testing.T) {
+	raw, err := Init0Packet{}.Marshal()
+	assert.NoError(t, err)
+	raw[17] = 2
+	assert.Error(t, (&Init0Packet{}).Unmarshal(raw))
+
+	raw, err = Init1Packet{}.Marshal()
+	assert.NoError(t, err)
+	raw[11] = 3
+	assert.Error(t, (&Init1Packet{}).Unmarshal(raw))
+
+	raw, err = Init3Packet{}.Marshal()
+	assert.NoError(t, err)
+	raw[11] = 1
+	assert.Error(t, (&Init3Packet{}).Unmarshal(raw))
+}
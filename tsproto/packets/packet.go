@@ -2,6 +2,8 @@ package packets
 
 import (
 	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
 )
 
 type PacketType int8
@@ -21,6 +23,15 @@ const (
 	PacketDirectionS2C     PacketDirection = 1
 )
 
+const (
+	// C2SHeaderSize is the size of client to server packet header
+	// MAC(8) + PacketId(2) + ClientId(2) + Type&Flags(1)
+	C2SHeaderSize = 13
+	// S2CHeaderSize is the size of server to client packet header
+	// MAC(8) + PacketId(2) + Type&Flags(1)
+	S2CHeaderSize = 11
+)
+
 func (pd PacketDirection) Direction() PacketDirection {
 	return pd
 }
@@ -54,27 +65,25 @@ type C2SPacket struct {
 }
 
 func (p C2SPacket) Marshal() ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	data := make([]byte, C2SHeaderSize)
+	copy(data[0:8], fillMAC(p.MAC))
+	binary.BigEndian.PutUint16(data[8:10], p.PacketId)
+	binary.BigEndian.PutUint16(data[10:12], p.ClientId)
+	data[12] = marshalTypeFlags(p.Encrypted, p.Compressed, p.NewProtocol, p.Fragmented, p.PacketType)
+	return data, nil
 }
 
 func (p *C2SPacket) Unmarshal(raw []byte) error {
-	p.MAC = string(raw[0:8])
-	p.PacketId = binary.BigEndian.Uint16(raw[8:10])
-	p.ClientId = binary.BigEndian.Uint16(raw[10:12])
+	if len(raw) < C2SHeaderSize {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 13")
+	}
 
-	// parse packet type and flags
 	p.MAC = string(raw[0:8])
 	p.PacketId = binary.BigEndian.Uint16(raw[8:10])
 	p.ClientId = binary.BigEndian.Uint16(raw[10:12])
 
 	// parse packet type and flags
-	pt := raw[12]
-	p.Encrypted = pt>>7&1 == 0
-	p.Compressed = pt>>6&1 == 1
-	p.NewProtocol = pt>>5&1 == 1
-	p.Fragmented = pt>>4&1 == 1
-	p.PacketType = PacketType(pt & ((1 << 4) - 1))
+	p.Encrypted, p.Compressed, p.NewProtocol, p.Fragmented, p.PacketType = unmarshalTypeFlags(raw[12])
 	return nil
 }
 
@@ -90,40 +99,22 @@ type S2CPacket struct {
 }
 
 func (p S2CPacket) Marshal() ([]byte, error) {
-	if len(p.MAC) < 8 {
-		p.MAC = "00000000"
-	}
-	data := append([]byte(p.MAC), byte(p.PacketId>>8), byte(p.PacketId&0xff))
+	data := make([]byte, S2CHeaderSize)
+	copy(data[0:8], fillMAC(p.MAC))
+	binary.BigEndian.PutUint16(data[8:10], p.PacketId)
+	data[10] = marshalTypeFlags(p.Encrypted, p.Compressed, p.NewProtocol, p.Fragmented, p.PacketType)
+	return data, nil
+}
 
-	var pt uint8
-	if p.Encrypted {
-		pt = 0b0
-	} else {
-		pt = 0b1
-	}
-	if p.Compressed {
-		pt = pt<<1 + 1
-	} else {
-		pt = pt<<1 + 0
-	}
-	if p.NewProtocol {
-		pt = pt<<1 + 1
-	} else {
-		pt = pt<<1 + 0
+func (p *S2CPacket) Unmarshal(raw []byte) error {
+	if len(raw) < S2CHeaderSize {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 11")
 	}
-	if p.Fragmented {
-		pt = pt<<1 + 1
-	} else {
-		pt = pt<<1 + 0
-	}
-	pt = pt<<4 + uint8(p.PacketType)
-
-	data = append(data, pt)
 
-	return data, nil
-}
+	p.MAC = string(raw[0:8])
+	p.PacketId = binary.BigEndian.Uint16(raw[8:10])
 
-func (p S2CPacket) Unmarshal(bytes []byte) error {
-	//TODO implement me
-	panic("implement me")
+	// parse packet type and flags
+	p.Encrypted, p.Compressed, p.NewProtocol, p.Fragmented, p.PacketType = unmarshalTypeFlags(raw[10])
+	return nil
 }
@@ -0,0 +1,127 @@
+package packets
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allPacketTypes = []PacketType{
+	PacketTypeVoice,
+	PacketTypeVoiceWhisper,
+	PacketTypeCommand,
+	PacketTypeCommandLow,
+	PacketTypePing,
+	PacketTypePong,
+	PacketTypeAck,
+	PacketTypeAckLow,
+	PacketTypeInit1,
+}
+
+func randomMAC(r *rand.Rand) string {
+	mac := make([]byte, 8)
+	r.Read(mac)
+	return string(mac)
+}
+
+func TestC2SPacketRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, pt := range allPacketTypes {
+		for flags := 0; flags < 16; flags++ {
+			h := C2SPacket{
+				MAC:         randomMAC(r),
+				PacketId:    uint16(r.Intn(65536)),
+				ClientId:    uint16(r.Intn(65536)),
+				Encrypted:   flags&1 != 0,
+				Compressed:  flags&2 != 0,
+				NewProtocol: flags&4 != 0,
+				Fragmented:  flags&8 != 0,
+				PacketType:  pt,
+			}
+			raw, err := h.Marshal()
+			assert.NoError(t, err)
+			assert.Len(t, raw, C2SHeaderSize)
+
+			var decoded C2SPacket
+			assert.NoError(t, decoded.Unmarshal(raw))
+			assert.Equal(t, h, decoded)
+		}
+	}
+}
+
+func TestS2CPacketRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, pt := range allPacketTypes {
+		for flags := 0; flags < 16; flags++ {
+			h := S2CPacket{
+				MAC:         randomMAC(r),
+				PacketId:    uint16(r.Intn(65536)),
+				Encrypted:   flags&1 != 0,
+				Compressed:  flags&2 != 0,
+				NewProtocol: flags&4 != 0,
+				Fragmented:  flags&8 != 0,
+				PacketType:  pt,
+			}
+			raw, err := h.Marshal()
+			assert.NoError(t, err)
+			assert.Len(t, raw, S2CHeaderSize)
+
+			var decoded S2CPacket
+			assert.NoError(t, decoded.Unmarshal(raw))
+			assert.Equal(t, h, decoded)
+		}
+	}
+}
+
+func TestPacketHeaderQuick(t *testing.T) {
+	c2s := func(mac [8]byte, packetId, clientId uint16, flags uint8) bool {
+		h := C2SPacket{
+			MAC:         string(mac[:]),
+			PacketId:    packetId,
+			ClientId:    clientId,
+			Encrypted:   flags&0x80 == 0,
+			Compressed:  flags&0x40 != 0,
+			NewProtocol: flags&0x20 != 0,
+			Fragmented:  flags&0x10 != 0,
+			PacketType:  PacketType(flags & 0x0f),
+		}
+		raw, err := h.Marshal()
+		if err != nil || raw[12] != flags {
+			return false
+		}
+		var decoded C2SPacket
+		return decoded.Unmarshal(raw) == nil && decoded == h
+	}
+	assert.NoError(t, quick.Check(c2s, nil))
+
+	s2c := func(mac [8]byte, packetId uint16, flags uint8) bool {
+		h := S2CPacket{
+			MAC:         string(mac[:]),
+			PacketId:    packetId,
+			Encrypted:   flags&0x80 == 0,
+			Compressed:  flags&0x40 != 0,
+			NewProtocol: flags&0x20 != 0,
+			Fragmented:  flags&0x10 != 0,
+			PacketType:  PacketType(flags & 0x0f),
+		}
+		raw, err := h.Marshal()
+		if err != nil || raw[10] != flags {
+			return false
+		}
+		var decoded S2CPacket
+		return decoded.Unmarshal(raw) == nil && decoded == h
+	}
+	assert.NoError(t, quick.Check(s2c, nil))
+}
+
+func TestPacketHeaderTruncated(t *testing.T) {
+	raw := make([]byte, C2SHeaderSize)
+	for i := 0; i < C2SHeaderSize; i++ {
+		assert.Error(t, (&C2SPacket{}).Unmarshal(raw[:i]))
+	}
+	for i := 0; i < S2CHeaderSize; i++ {
+		assert.Error(t, (&S2CPacket{}).Unmarshal(raw[:i]))
+	}
+}
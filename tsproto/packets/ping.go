@@ -0,0 +1,95 @@
+package packets
+
+import (
+	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+// PingPacket carries nothing but the packet header
+type PingPacket struct {
+	PacketDirection
+	C2S *C2SPacket
+	S2C *S2CPacket
+}
+
+func (p PingPacket) Marshal() ([]byte, error) {
+	if p.C2S != nil {
+		return p.C2S.Marshal()
+	}
+	return p.S2C.Marshal()
+}
+
+func (p *PingPacket) Unmarshal(raw []byte) error {
+	if p.Direction() == PacketDirectionC2S {
+		p.C2S = &C2SPacket{}
+		return p.C2S.Unmarshal(raw)
+	}
+	p.S2C = &S2CPacket{}
+	return p.S2C.Unmarshal(raw)
+}
+
+func (p PingPacket) isPacket() {}
+
+// PacketId returns the packet id of the ping, which is echoed by the pong
+func (p PingPacket) PacketId() uint16 {
+	if p.C2S != nil {
+		return p.C2S.PacketId
+	}
+	return p.S2C.PacketId
+}
+
+type PongPacket struct {
+	PacketDirection
+	C2S *C2SPacket
+	S2C *S2CPacket
+	// 02 bytes : The packet id of the answered ping
+	PingId uint16
+}
+
+func (p PongPacket) Marshal() ([]byte, error) {
+	var (
+		header []byte
+		err    error
+	)
+	if p.C2S != nil {
+		header, err = p.C2S.Marshal()
+	} else {
+		header, err = p.S2C.Marshal()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(header, byte(p.PingId>>8), byte(p.PingId)), nil
+}
+
+func (p *PongPacket) Unmarshal(raw []byte) error {
+	var (
+		dataOffset int
+		err        error
+	)
+
+	if p.Direction() == PacketDirectionC2S {
+		if len(raw) < C2SHeaderSize+2 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 15")
+		}
+		dataOffset = C2SHeaderSize
+		p.C2S = &C2SPacket{}
+		err = p.C2S.Unmarshal(raw)
+	} else {
+		if len(raw) < S2CHeaderSize+2 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 13")
+		}
+		dataOffset = S2CHeaderSize
+		p.S2C = &S2CPacket{}
+		err = p.S2C.Unmarshal(raw)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.PingId = binary.BigEndian.Uint16(raw[dataOffset : dataOffset+2])
+	return nil
+}
+
+func (p PongPacket) isPacket() {}
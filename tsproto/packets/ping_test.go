@@ -0,0 +1,35 @@
+package packets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingPong(t *testing.T) {
+	ping := PingPacket{
+		C2S: &C2SPacket{MAC: "12345678", PacketId: 77, ClientId: 1, PacketType: PacketTypePing},
+	}
+	raw, err := ping.Marshal()
+	assert.NoError(t, err)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	receivedPing := p.(*PingPacket)
+	assert.Equal(t, uint16(77), receivedPing.PacketId())
+
+	pong := PongPacket{
+		S2C:    &S2CPacket{MAC: "87654321", PacketId: 3, PacketType: PacketTypePong},
+		PingId: receivedPing.PacketId(),
+	}
+	raw, err = pong.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, S2CHeaderSize+2)
+
+	p, err = Decode(raw, PacketDirectionS2C)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(77), p.(*PongPacket).PingId)
+
+	_, err = Decode(raw[:S2CHeaderSize+1], PacketDirectionS2C)
+	assert.Error(t, err)
+}
@@ -1 +1,54 @@
 package packets
+
+import "encoding/binary"
+
+const (
+	flagUnencrypted = 0x80
+	flagCompressed  = 0x40
+	flagNewProtocol = 0x20
+	flagFragmented  = 0x10
+	packetTypeMask  = 0x0f
+)
+
+// fillMAC returns the 8 bytes MAC field of packet header,
+// a shorter MAC will be replaced by the placeholder and a longer one will be truncated
+func fillMAC(mac string) []byte {
+	if len(mac) < 8 {
+		mac = "00000000"
+	}
+	return []byte(mac[:8])
+}
+
+// marshalTypeFlags packs the flags and packet type into the last byte of packet header
+// the highest bit is the "Unencrypted" flag, so it is set when the packet is NOT encrypted
+func marshalTypeFlags(encrypted, compressed, newProtocol, fragmented bool, t PacketType) byte {
+	pt := byte(t) & packetTypeMask
+	if !encrypted {
+		pt |= flagUnencrypted
+	}
+	if compressed {
+		pt |= flagCompressed
+	}
+	if newProtocol {
+		pt |= flagNewProtocol
+	}
+	if fragmented {
+		pt |= flagFragmented
+	}
+	return pt
+}
+
+// unmarshalTypeFlags unpacks the last byte of packet header into flags and packet type
+func unmarshalTypeFlags(pt byte) (encrypted, compressed, newProtocol, fragmented bool, t PacketType) {
+	return pt&flagUnencrypted == 0,
+		pt&flagCompressed != 0,
+		pt&flagNewProtocol != 0,
+		pt&flagFragmented != 0,
+		PacketType(pt & packetTypeMask)
+}
+
+func binaryAppendUint64(data []byte, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return append(data, b...)
+}
@@ -0,0 +1,113 @@
+package packets
+
+import (
+	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+type Codec uint8
+
+const (
+	CodecSpeexNarrowband    Codec = 0
+	CodecSpeexWideband      Codec = 1
+	CodecSpeexUltraWideband Codec = 2
+	CodecCeltMono           Codec = 3
+	CodecOpusVoice          Codec = 4
+	CodecOpusMusic          Codec = 5
+)
+
+type VoicePacket struct {
+	PacketDirection
+	C2S *C2SPacket
+	S2C *S2CPacket
+	// 02 bytes : Voice packet id
+	VoicePacketId uint16
+	// 02 bytes : Talking client id, only exists in S2C
+	ClientId uint16
+	// 01 bytes : Codec type
+	Codec Codec
+	// var bytes : Voice data encoded by the codec, kept as-is
+	Data []byte
+}
+
+func (vp VoicePacket) Marshal() ([]byte, error) {
+	dir, err := headerDirection(vp.Direction(), vp.C2S, vp.S2C)
+	if err != nil {
+		return nil, err
+	}
+
+	var header []byte
+	if dir == PacketDirectionC2S {
+		header, err = vp.C2S.Marshal()
+	} else {
+		header, err = vp.S2C.Marshal()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(header, byte(vp.VoicePacketId>>8), byte(vp.VoicePacketId))
+	if dir == PacketDirectionS2C {
+		data = append(data, byte(vp.ClientId>>8), byte(vp.ClientId))
+	}
+	data = append(data, byte(vp.Codec))
+	return append(data, vp.Data...), nil
+}
+
+func (vp *VoicePacket) Unmarshal(raw []byte) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	if vp.Direction() == PacketDirectionC2S {
+		if len(raw) < C2SHeaderSize+3 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 16")
+		}
+		vp.C2S = &C2SPacket{}
+		err = vp.C2S.Unmarshal(raw)
+		data = raw[C2SHeaderSize:]
+	} else {
+		if len(raw) < S2CHeaderSize+5 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 16")
+		}
+		vp.S2C = &S2CPacket{}
+		err = vp.S2C.Unmarshal(raw)
+		data = raw[S2CHeaderSize:]
+	}
+	if err != nil {
+		return err
+	}
+
+	vp.VoicePacketId = binary.BigEndian.Uint16(data[0:2])
+	data = data[2:]
+	if vp.Direction() == PacketDirectionS2C {
+		vp.ClientId = binary.BigEndian.Uint16(data[0:2])
+		data = data[2:]
+	}
+	vp.Codec = Codec(data[0])
+	vp.Data = append([]byte{}, data[1:]...)
+	return nil
+}
+
+func (vp VoicePacket) isPacket() {}
+
+// headerDirection returns the direction of the header set on a voice packet,
+// exactly one header has to be set. The zero direction is C2S, so only a C2S
+// header on a packet declared S2C contradicts the direction.
+func headerDirection(declared PacketDirection, c2s *C2SPacket, s2c *S2CPacket) (PacketDirection, error) {
+	switch {
+	case c2s != nil && s2c != nil:
+		return 0, tsErrors.Errorf(tsErrors.InvalidPacket, "both C2S and S2C header are set")
+	case c2s != nil:
+		if declared == PacketDirectionS2C {
+			return 0, tsErrors.Errorf(tsErrors.InvalidPacket, "C2S header on a S2C packet")
+		}
+		return PacketDirectionC2S, nil
+	case s2c != nil:
+		return PacketDirectionS2C, nil
+	default:
+		return 0, tsErrors.Errorf(tsErrors.InvalidPacket, "no header is set")
+	}
+}
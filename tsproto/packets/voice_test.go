@@ -0,0 +1,78 @@
+package packets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func TestVoicePacketRoundTrip(t *testing.T) {
+	c2s := VoicePacket{
+		C2S:           &C2SPacket{MAC: "12345678", PacketId: 10, ClientId: 2, PacketType: PacketTypeVoice},
+		VoicePacketId: 10,
+		Codec:         CodecOpusVoice,
+		Data:          []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+	raw, err := c2s.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, C2SHeaderSize+3+4)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	decoded := p.(*VoicePacket)
+	assert.Equal(t, *c2s.C2S, *decoded.C2S)
+	assert.Equal(t, c2s.VoicePacketId, decoded.VoicePacketId)
+	assert.Equal(t, c2s.Codec, decoded.Codec)
+	assert.Equal(t, c2s.Data, decoded.Data)
+
+	// server relays the voice with the talking client id
+	s2c := VoicePacket{
+		S2C:           &S2CPacket{MAC: "87654321", PacketId: 11, PacketType: PacketTypeVoice},
+		VoicePacketId: decoded.VoicePacketId,
+		ClientId:      decoded.C2S.ClientId,
+		Codec:         decoded.Codec,
+		Data:          decoded.Data,
+	}
+	raw, err = s2c.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, S2CHeaderSize+5+4)
+
+	p, err = Decode(raw, PacketDirectionS2C)
+	assert.NoError(t, err)
+	decoded = p.(*VoicePacket)
+	assert.Equal(t, *s2c.S2C, *decoded.S2C)
+	assert.Equal(t, uint16(2), decoded.ClientId)
+	assert.Equal(t, s2c.Codec, decoded.Codec)
+	assert.Equal(t, s2c.Data, decoded.Data)
+
+	// empty voice data marks the end of a voice transmission
+	_, err = Decode(raw[:S2CHeaderSize+5], PacketDirectionS2C)
+	assert.NoError(t, err)
+	_, err = Decode(raw[:S2CHeaderSize+4], PacketDirectionS2C)
+	assert.Error(t, err)
+}
+
+func TestVoicePacketHeader(t *testing.T) {
+	c2s := &C2SPacket{PacketType: PacketTypeVoice}
+	s2c := &S2CPacket{PacketType: PacketTypeVoice}
+	for _, vp := range []VoicePacket{
+		{},
+		{PacketDirection: PacketDirectionS2C},
+		{C2S: c2s, S2C: s2c},
+		{PacketDirection: PacketDirectionS2C, C2S: c2s},
+	} {
+		_, err := vp.Marshal()
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidPacket))
+	}
+
+	// the direction is taken from the header
+	raw, err := VoicePacket{S2C: s2c, ClientId: 3}.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, S2CHeaderSize+5)
+	raw, err = VoicePacket{PacketDirection: PacketDirectionS2C, S2C: s2c}.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, S2CHeaderSize+5)
+}
@@ -0,0 +1,162 @@
+package packets
+
+import (
+	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+type WhisperType uint8
+
+const (
+	WhisperTypeServerGroup      WhisperType = 0
+	WhisperTypeChannelGroup     WhisperType = 1
+	WhisperTypeChannelCommander WhisperType = 2
+	WhisperTypeAllClients       WhisperType = 3
+)
+
+type WhisperTarget uint8
+
+const (
+	WhisperTargetAllChannels           WhisperTarget = 0
+	WhisperTargetCurrentChannel        WhisperTarget = 1
+	WhisperTargetParentChannel         WhisperTarget = 2
+	WhisperTargetAllParentChannel      WhisperTarget = 3
+	WhisperTargetChannelFamily         WhisperTarget = 4
+	WhisperTargetCompleteChannelFamily WhisperTarget = 5
+	WhisperTargetSubchannels           WhisperTarget = 6
+)
+
+// VoiceWhisperPacket is the voice packet sent to selected targets. The C2S packet
+// carries the targets as lists of channel and client ids, or as whisper type and
+// target when the NewProtocol flag is set. The S2C packet carries no target.
+type VoiceWhisperPacket struct {
+	PacketDirection
+	C2S *C2SPacket
+	S2C *S2CPacket
+	// 02 bytes : Voice packet id
+	VoicePacketId uint16
+	// 02 bytes : Talking client id, only exists in S2C
+	ClientId uint16
+	// 01 bytes : Codec type
+	Codec Codec
+
+	// 01 bytes : Count of ChannelIds
+	// 01 bytes : Count of ClientIds
+	// N * 08 bytes : Target channel ids
+	ChannelIds []uint64
+	// M * 02 bytes : Target client ids
+	ClientIds []uint16
+
+	// 01 bytes : Whisper type, only exists with NewProtocol
+	WhisperType WhisperType
+	// 01 bytes : Whisper target, only exists with NewProtocol
+	Target WhisperTarget
+	// 08 bytes : Target id, e.g. the group id, only exists with NewProtocol
+	TargetId uint64
+
+	// var bytes : Voice data encoded by the codec, kept as-is
+	Data []byte
+}
+
+func (wp VoiceWhisperPacket) Marshal() ([]byte, error) {
+	dir, err := headerDirection(wp.Direction(), wp.C2S, wp.S2C)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == PacketDirectionS2C {
+		header, err := wp.S2C.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		data := append(header, byte(wp.VoicePacketId>>8), byte(wp.VoicePacketId))
+		data = append(data, byte(wp.ClientId>>8), byte(wp.ClientId), byte(wp.Codec))
+		return append(data, wp.Data...), nil
+	}
+
+	header, err := wp.C2S.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	data := append(header, byte(wp.VoicePacketId>>8), byte(wp.VoicePacketId), byte(wp.Codec))
+
+	if wp.C2S.NewProtocol {
+		data = append(data, byte(wp.WhisperType), byte(wp.Target))
+		data = binaryAppendUint64(data, wp.TargetId)
+	} else {
+		if len(wp.ChannelIds) > 255 || len(wp.ClientIds) > 255 {
+			return nil, tsErrors.Errorf(tsErrors.InvalidPacket, "too many whisper targets")
+		}
+		data = append(data, byte(len(wp.ChannelIds)), byte(len(wp.ClientIds)))
+		for _, id := range wp.ChannelIds {
+			data = binaryAppendUint64(data, id)
+		}
+		for _, id := range wp.ClientIds {
+			data = append(data, byte(id>>8), byte(id))
+		}
+	}
+	return append(data, wp.Data...), nil
+}
+
+func (wp *VoiceWhisperPacket) Unmarshal(raw []byte) error {
+	if wp.Direction() == PacketDirectionS2C {
+		if len(raw) < S2CHeaderSize+5 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 16")
+		}
+		wp.S2C = &S2CPacket{}
+		err := wp.S2C.Unmarshal(raw)
+		if err != nil {
+			return err
+		}
+		data := raw[S2CHeaderSize:]
+		wp.VoicePacketId = binary.BigEndian.Uint16(data[0:2])
+		wp.ClientId = binary.BigEndian.Uint16(data[2:4])
+		wp.Codec = Codec(data[4])
+		wp.Data = append([]byte{}, data[5:]...)
+		return nil
+	}
+
+	if len(raw) < C2SHeaderSize+5 {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 18")
+	}
+	wp.C2S = &C2SPacket{}
+	err := wp.C2S.Unmarshal(raw)
+	if err != nil {
+		return err
+	}
+	data := raw[C2SHeaderSize:]
+	wp.VoicePacketId = binary.BigEndian.Uint16(data[0:2])
+	wp.Codec = Codec(data[2])
+
+	if wp.C2S.NewProtocol {
+		if len(data) < 13 {
+			return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), ">= 26")
+		}
+		wp.WhisperType = WhisperType(data[3])
+		wp.Target = WhisperTarget(data[4])
+		wp.TargetId = binary.BigEndian.Uint64(data[5:13])
+		wp.Data = append([]byte{}, data[13:]...)
+		return nil
+	}
+
+	channelCount, clientCount := int(data[3]), int(data[4])
+	offset := 5
+	if len(data) < offset+channelCount*8+clientCount*2 {
+		return tsErrors.Errorf(tsErrors.PacketIncomplete, len(raw), C2SHeaderSize+offset+channelCount*8+clientCount*2)
+	}
+	wp.ChannelIds = make([]uint64, channelCount)
+	for i := range wp.ChannelIds {
+		wp.ChannelIds[i] = binary.BigEndian.Uint64(data[offset : offset+8])
+		offset += 8
+	}
+	wp.ClientIds = make([]uint16, clientCount)
+	for i := range wp.ClientIds {
+		wp.ClientIds[i] = binary.BigEndian.Uint16(data[offset : offset+2])
+		offset += 2
+	}
+	wp.Data = append([]byte{}, data[offset:]...)
+	return nil
+}
+
+func (wp VoiceWhisperPacket) isPacket() {}
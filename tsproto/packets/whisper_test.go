@@ -0,0 +1,103 @@
+package packets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func TestVoiceWhisperClassic(t *testing.T) {
+	wp := VoiceWhisperPacket{
+		C2S:           &C2SPacket{MAC: "12345678", PacketId: 5, ClientId: 3, PacketType: PacketTypeVoiceWhisper},
+		VoicePacketId: 5,
+		Codec:         CodecOpusVoice,
+		ChannelIds:    []uint64{1, 1 << 40},
+		ClientIds:     []uint16{7, 8, 9},
+		Data:          []byte{0x01, 0x02, 0x03},
+	}
+	raw, err := wp.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, C2SHeaderSize+5+2*8+3*2+3)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	decoded := p.(*VoiceWhisperPacket)
+	assert.Equal(t, wp.VoicePacketId, decoded.VoicePacketId)
+	assert.Equal(t, wp.Codec, decoded.Codec)
+	assert.Equal(t, wp.ChannelIds, decoded.ChannelIds)
+	assert.Equal(t, wp.ClientIds, decoded.ClientIds)
+	assert.Equal(t, wp.Data, decoded.Data)
+
+	// truncated target list
+	_, err = Decode(raw[:C2SHeaderSize+5+2*8+3*2-1], PacketDirectionC2S)
+	assert.Error(t, err)
+
+	wp.ClientIds = make([]uint16, 256)
+	_, err = wp.Marshal()
+	assert.Error(t, err)
+}
+
+func TestVoiceWhisperNewProtocol(t *testing.T) {
+	wp := VoiceWhisperPacket{
+		C2S: &C2SPacket{
+			MAC:         "12345678",
+			PacketId:    6,
+			ClientId:    3,
+			NewProtocol: true,
+			PacketType:  PacketTypeVoiceWhisper,
+		},
+		VoicePacketId: 6,
+		Codec:         CodecOpusVoice,
+		WhisperType:   WhisperTypeChannelCommander,
+		Target:        WhisperTargetChannelFamily,
+		TargetId:      42,
+		Data:          []byte{0x04, 0x05},
+	}
+	raw, err := wp.Marshal()
+	assert.NoError(t, err)
+	assert.Len(t, raw, C2SHeaderSize+13+2)
+
+	p, err := Decode(raw, PacketDirectionC2S)
+	assert.NoError(t, err)
+	decoded := p.(*VoiceWhisperPacket)
+	assert.Equal(t, wp.WhisperType, decoded.WhisperType)
+	assert.Equal(t, wp.Target, decoded.Target)
+	assert.Equal(t, wp.TargetId, decoded.TargetId)
+	assert.Nil(t, decoded.ChannelIds)
+	assert.Equal(t, wp.Data, decoded.Data)
+
+	_, err = Decode(raw[:C2SHeaderSize+12], PacketDirectionC2S)
+	assert.Error(t, err)
+}
+
+func TestVoiceWhisperS2C(t *testing.T) {
+	wp := VoiceWhisperPacket{
+		S2C:           &S2CPacket{MAC: "12345678", PacketId: 9, PacketType: PacketTypeVoiceWhisper},
+		VoicePacketId: 9,
+		ClientId:      3,
+		Codec:         CodecOpusMusic,
+		ChannelIds:    []uint64{1},
+		Data:          []byte{0x06},
+	}
+	raw, err := wp.Marshal()
+	assert.NoError(t, err)
+	// targets are stripped
+	assert.Len(t, raw, S2CHeaderSize+5+1)
+
+	p, err := Decode(raw, PacketDirectionS2C)
+	assert.NoError(t, err)
+	decoded := p.(*VoiceWhisperPacket)
+	assert.Equal(t, wp.ClientId, decoded.ClientId)
+	assert.Equal(t, wp.Codec, decoded.Codec)
+	assert.Nil(t, decoded.ChannelIds)
+	assert.Equal(t, wp.Data, decoded.Data)
+
+	// a C2S header contradicts the S2C direction
+	wp.PacketDirection = PacketDirectionS2C
+	wp.C2S, wp.S2C = &C2SPacket{PacketType: PacketTypeVoiceWhisper}, nil
+	_, err = wp.Marshal()
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidPacket))
+}
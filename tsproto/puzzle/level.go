@@ -0,0 +1,147 @@
+package puzzle
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMinLevel    = DefaultLevel
+	DefaultMaxLevel    = MaxLevel
+	DefaultIdlePending = 16
+	DefaultLevelWindow = time.Minute
+)
+
+// LevelPolicy picks the puzzle level of the handshakes, a source is what the
+// server uses to tell clients apart, e.g. the IP address
+type LevelPolicy interface {
+	// Level returns the level of a new handshake of the source
+	Level(source string, now time.Time) uint32
+	// Done ends a handshake of the source, solved is false when the answer was wrong
+	Done(source string, solved bool, now time.Time)
+}
+
+// FixedLevel is a LevelPolicy using the same level for all handshakes
+type FixedLevel uint32
+
+func (l FixedLevel) Level(string, time.Time) uint32 {
+	return uint32(l)
+}
+
+func (l FixedLevel) Done(string, bool, time.Time) {}
+
+// AdaptiveLevel is a LevelPolicy raising the level with the load. The level starts
+// at MinLevel and doubles
+//   - whenever the count of pending handshakes doubles beyond Idle,
+//   - for every other handshake of the source still pending,
+//   - for every wrong answer or abandoned handshake of the source in Window,
+//
+// so an idle server is cheap to connect to while floods pay up to MaxLevel.
+type AdaptiveLevel struct {
+	MinLevel uint32
+	MaxLevel uint32
+	Idle     int
+	Window   time.Duration
+
+	mu       sync.Mutex
+	sources  map[string]*sourceHistory
+	pending  int
+	prunedAt time.Time
+}
+
+// sourceHistory is the recent handshakes of a source
+type sourceHistory struct {
+	// start time of the handshakes not done yet
+	pending  []time.Time
+	failures int
+	last     time.Time
+}
+
+// NewAdaptiveLevel creates an AdaptiveLevel with the default bounds
+func NewAdaptiveLevel() *AdaptiveLevel {
+	return &AdaptiveLevel{
+		MinLevel: DefaultMinLevel,
+		MaxLevel: DefaultMaxLevel,
+		Idle:     DefaultIdlePending,
+		Window:   DefaultLevelWindow,
+	}
+}
+
+func (a *AdaptiveLevel) Level(source string, now time.Time) uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sources == nil {
+		a.sources = make(map[string]*sourceHistory)
+	}
+	if now.Sub(a.prunedAt) >= a.Window/4 {
+		a.prune(now)
+	}
+
+	h, ok := a.sources[source]
+	if !ok || now.Sub(h.last) > a.Window {
+		if ok {
+			a.pending -= len(h.pending)
+		}
+		h = &sourceHistory{}
+		a.sources[source] = h
+	}
+
+	shift := 0
+	for threshold := a.Idle; threshold > 0 && a.pending >= threshold; threshold *= 2 {
+		shift++
+	}
+	shift += len(h.pending) + h.failures
+
+	h.pending = append(h.pending, now)
+	h.last = now
+	a.pending++
+
+	level := uint64(a.MinLevel)
+	for i := 0; i < shift && level < uint64(a.MaxLevel); i++ {
+		level *= 2
+	}
+	if level > uint64(a.MaxLevel) {
+		level = uint64(a.MaxLevel)
+	}
+	return uint32(level)
+}
+
+func (a *AdaptiveLevel) Done(source string, solved bool, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h, ok := a.sources[source]
+	if !ok || len(h.pending) == 0 {
+		return
+	}
+	h.pending = h.pending[1:]
+	a.pending--
+	if !solved {
+		h.failures++
+	}
+	h.last = now
+}
+
+// Pending returns the count of handshakes not done yet
+func (a *AdaptiveLevel) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pending
+}
+
+// prune counts the handshakes pending for longer than Window as abandoned and
+// forgets the sources idle for longer than Window, the caller holds mu
+func (a *AdaptiveLevel) prune(now time.Time) {
+	a.prunedAt = now
+	for source, h := range a.sources {
+		for len(h.pending) > 0 && now.Sub(h.pending[0]) > a.Window {
+			h.pending = h.pending[1:]
+			h.failures++
+			a.pending--
+		}
+		if len(h.pending) == 0 && now.Sub(h.last) > a.Window {
+			delete(a.sources, source)
+		}
+	}
+}
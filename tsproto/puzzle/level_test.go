@@ -0,0 +1,97 @@
+package puzzle
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedLevel(t *testing.T) {
+	var policy LevelPolicy = FixedLevel(500)
+	assert.Equal(t, uint32(500), policy.Level("a", time.Now()))
+}
+
+func TestAdaptiveLevelIdle(t *testing.T) {
+	a := NewAdaptiveLevel()
+	now := time.Unix(1700000000, 0)
+
+	// different sources on an idle server get the minimum
+	for i := 0; i < DefaultIdlePending; i++ {
+		source := fmt.Sprintf("10.0.0.%d", i)
+		assert.Equal(t, uint32(DefaultMinLevel), a.Level(source, now))
+		a.Done(source, true, now)
+	}
+	assert.Equal(t, 0, a.Pending())
+}
+
+func TestAdaptiveLevelLoad(t *testing.T) {
+	a := NewAdaptiveLevel()
+	a.Idle = 4
+	now := time.Unix(1700000000, 0)
+
+	var levels []uint32
+	for i := 0; i < 17; i++ {
+		levels = append(levels, a.Level(fmt.Sprintf("10.0.0.%d", i), now))
+	}
+	// doubles at 4, 8 and 16 pending handshakes
+	assert.Equal(t, uint32(DefaultMinLevel), levels[3])
+	assert.Equal(t, uint32(DefaultMinLevel*2), levels[4])
+	assert.Equal(t, uint32(DefaultMinLevel*4), levels[8])
+	assert.Equal(t, uint32(DefaultMinLevel*8), levels[16])
+	assert.Equal(t, 17, a.Pending())
+
+	// abandoned handshakes expire after the window
+	now = now.Add(2 * DefaultLevelWindow)
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("10.0.1.1", now))
+	assert.Equal(t, 1, a.Pending())
+}
+
+func TestAdaptiveLevelSource(t *testing.T) {
+	a := NewAdaptiveLevel()
+	now := time.Unix(1700000000, 0)
+
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("flood", now))
+	a.Done("flood", false, now)
+	// one failure
+	assert.Equal(t, uint32(DefaultMinLevel*2), a.Level("flood", now))
+	a.Done("flood", true, now)
+	// a solved handshake does not raise the level
+	assert.Equal(t, uint32(DefaultMinLevel*2), a.Level("flood", now))
+	// a concurrent handshake still pending does
+	assert.Equal(t, uint32(DefaultMinLevel*4), a.Level("flood", now))
+	a.Done("flood", true, now)
+
+	// capped at the maximum
+	for i := 0; i < 20; i++ {
+		a.Done("flood", false, now)
+		a.Level("flood", now)
+	}
+	assert.Equal(t, uint32(DefaultMaxLevel), a.Level("flood", now))
+
+	// other sources are not affected, the history is forgotten after the window
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("other", now))
+	now = now.Add(2 * DefaultLevelWindow)
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("flood", now))
+}
+
+func TestAdaptiveLevelReconnect(t *testing.T) {
+	a := NewAdaptiveLevel()
+	now := time.Unix(1700000000, 0)
+
+	// a client reconnecting from the same address keeps the minimum
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, uint32(DefaultMinLevel), a.Level("10.0.0.1", now))
+		a.Done("10.0.0.1", true, now)
+		now = now.Add(time.Second)
+	}
+
+	// handshakes of the source pending at the same time raise the level
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("10.0.0.1", now))
+	assert.Equal(t, uint32(DefaultMinLevel*2), a.Level("10.0.0.1", now))
+	a.Done("10.0.0.1", true, now)
+	a.Done("10.0.0.1", true, now)
+	assert.Equal(t, uint32(DefaultMinLevel), a.Level("10.0.0.1", now))
+	assert.Equal(t, 1, a.Pending())
+}
@@ -0,0 +1,84 @@
+package puzzle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPoolSize = 32
+	// poolRetryDelay is the wait before generating again after a failure
+	poolRetryDelay = 100 * time.Millisecond
+)
+
+// Pool pre-generates puzzles in the background, so handing out a puzzle does not
+// cost the generation of a modulus
+type Pool struct {
+	puzzles chan *Puzzle
+	random  io.Reader
+	stop    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// NewPool creates a Pool which keeps up to size puzzles ready and starts
+// generating them
+func NewPool(size int) *Pool {
+	return newPool(size, nil)
+}
+
+func newPool(size int, random io.Reader) *Pool {
+	p := &Pool{
+		puzzles: make(chan *Puzzle, size),
+		random:  random,
+		stop:    make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.fill()
+	return p
+}
+
+func (p *Pool) fill() {
+	defer p.wg.Done()
+	for {
+		puzzle, err := Generate(p.random)
+		if err != nil {
+			// wait before retrying, a failing random source would spin otherwise
+			select {
+			case <-time.After(poolRetryDelay):
+				continue
+			case <-p.stop:
+				return
+			}
+		}
+		select {
+		case p.puzzles <- puzzle:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Get takes a puzzle from the pool, it generates one when the pool is empty
+func (p *Pool) Get() (*Puzzle, error) {
+	select {
+	case puzzle := <-p.puzzles:
+		return puzzle, nil
+	default:
+		return Generate(p.random)
+	}
+}
+
+// Ready returns the count of puzzles ready in the pool
+func (p *Pool) Ready() int {
+	return len(p.puzzles)
+}
+
+// Close stops the background generation
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	p.wg.Wait()
+}
@@ -0,0 +1,98 @@
+// Package puzzle implements the RSA puzzle of the low-level handshake. The client
+// computes y = x ^ (2 ^ level) mod n by squaring level times, the server keeps
+// phi(n) and verifies y with the exponent reduced to 2 ^ level mod phi(n).
+package puzzle
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+const (
+	// ModulusBits is the size of n, x, n and y are sent as 64 bytes
+	ModulusBits  = 512
+	DefaultLevel = 10000
+)
+
+var (
+	one = big.NewInt(1)
+	two = big.NewInt(2)
+)
+
+// Puzzle is a RSA puzzle with the secret phi(n) of its modulus
+type Puzzle struct {
+	X   *big.Int
+	N   *big.Int
+	phi *big.Int
+}
+
+// Generate creates a puzzle with n = p * q of two random primes
+func Generate(random io.Reader) (*Puzzle, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+
+	var p, q, n *big.Int
+	for {
+		var err error
+		p, err = rand.Prime(random, ModulusBits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err = rand.Prime(random, ModulusBits/2)
+		if err != nil {
+			return nil, err
+		}
+		n = new(big.Int).Mul(p, q)
+		if p.Cmp(q) != 0 && n.BitLen() == ModulusBits {
+			break
+		}
+	}
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+
+	// x in [2, n-1] and coprime to n
+	x := new(big.Int)
+	for {
+		var err error
+		x, err = rand.Int(random, new(big.Int).Sub(n, two))
+		if err != nil {
+			return nil, err
+		}
+		x.Add(x, two)
+		if new(big.Int).GCD(nil, nil, x, n).Cmp(one) == 0 {
+			break
+		}
+	}
+
+	return &Puzzle{X: x, N: n, phi: phi}, nil
+}
+
+// Answer returns y = x ^ (2 ^ level) mod n, computed with the exponent
+// reduced by phi(n)
+func (p *Puzzle) Answer(level uint32) *big.Int {
+	e := new(big.Int).Exp(two, big.NewInt(int64(level)), p.phi)
+	return new(big.Int).Exp(p.X, e, p.N)
+}
+
+// FillInit3 writes the puzzle and the level into an Init3Packet
+func (p *Puzzle) FillInit3(init3 *packets.Init3Packet, level uint32) {
+	p.X.FillBytes(init3.X[:])
+	p.N.FillBytes(init3.N[:])
+	init3.Level = level
+}
+
+// VerifyInit4 checks that the Init4Packet echoes the puzzle at the level and
+// answers it correctly
+func (p *Puzzle) VerifyInit4(init4 *packets.Init4Packet, level uint32) bool {
+	var x, n, y [64]byte
+	p.X.FillBytes(x[:])
+	p.N.FillBytes(n[:])
+	if init4.X != x || init4.N != n || init4.Level != level {
+		return false
+	}
+	p.Answer(level).FillBytes(y[:])
+	return init4.Y == y
+}
@@ -0,0 +1,116 @@
+package puzzle
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+// naiveAnswer squares x level times like a client does
+func naiveAnswer(x, n *big.Int, level uint32) *big.Int {
+	y := new(big.Int).Set(x)
+	for i := uint32(0); i < level; i++ {
+		y.Mul(y, y).Mod(y, n)
+	}
+	return y
+}
+
+func TestPuzzle(t *testing.T) {
+	p, err := Generate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ModulusBits, p.N.BitLen())
+	assert.True(t, p.X.Cmp(p.N) < 0)
+
+	for _, level := range []uint32{0, 1, 100, 1000} {
+		assert.Equal(t, naiveAnswer(p.X, p.N, level), p.Answer(level))
+	}
+
+	init3 := &packets.Init3Packet{}
+	p.FillInit3(init3, 100)
+	assert.Equal(t, uint32(100), init3.Level)
+
+	init4 := &packets.Init4Packet{X: init3.X, N: init3.N, Level: init3.Level}
+	naiveAnswer(p.X, p.N, 100).FillBytes(init4.Y[:])
+	assert.True(t, p.VerifyInit4(init4, 100))
+
+	// a wrong answer, level or puzzle
+	assert.False(t, p.VerifyInit4(init4, 101))
+	init4.Y[63] ^= 1
+	assert.False(t, p.VerifyInit4(init4, 100))
+	init4.Y[63] ^= 1
+	init4.X[63] ^= 1
+	assert.False(t, p.VerifyInit4(init4, 100))
+}
+
+func TestPool(t *testing.T) {
+	pool := NewPool(4)
+	defer pool.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for pool.Ready() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 4, pool.Ready())
+
+	a, err := pool.Get()
+	assert.NoError(t, err)
+	b, err := pool.Get()
+	assert.NoError(t, err)
+	assert.NotEqual(t, a.N, b.N)
+}
+
+type failingReader struct {
+	reads int32
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	atomic.AddInt32(&r.reads, 1)
+	return 0, errors.New("entropy source is unavailable")
+}
+
+func TestPoolGenerateError(t *testing.T) {
+	random := &failingReader{}
+	pool := newPool(4, random)
+
+	// failures are retried with a delay instead of spinning
+	time.Sleep(3 * poolRetryDelay)
+	assert.LessOrEqual(t, atomic.LoadInt32(&random.reads), int32(20))
+	assert.Equal(t, 0, pool.Ready())
+	_, err := pool.Get()
+	assert.Error(t, err)
+
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocks on a failing pool")
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = Generate(nil)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	p, _ := Generate(nil)
+	init3 := &packets.Init3Packet{}
+	p.FillInit3(init3, DefaultLevel)
+	init4 := &packets.Init4Packet{X: init3.X, N: init3.N, Level: init3.Level}
+	p.Answer(DefaultLevel).FillBytes(init4.Y[:])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.VerifyInit4(init4, DefaultLevel)
+	}
+}
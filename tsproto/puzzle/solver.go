@@ -0,0 +1,147 @@
+package puzzle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+const (
+	// MaxLevel is the highest level solved, a hostile server could keep a client
+	// busy for hours with a higher one
+	MaxLevel = 1000000
+	// progressInterval is the count of squarings between two progress reports
+	progressInterval = 1000
+)
+
+// ProgressFunc is called while solving with the squarings done so far
+type ProgressFunc func(done, total uint32)
+
+// BatchProgressFunc is called by SolveAll with the index of the puzzle, it may be
+// called from several goroutines at once
+type BatchProgressFunc func(index int, done, total uint32)
+
+// Solve computes y = x ^ (2 ^ level) mod n of the Init3Packet by squaring level
+// times. Every squaring needs the previous one, use SolveAll to solve several
+// puzzles in parallel. It returns the error of ctx when ctx is done before the
+// puzzle is solved.
+func Solve(ctx context.Context, init3 *packets.Init3Packet, progress ProgressFunc) ([64]byte, error) {
+	var y [64]byte
+
+	if init3.Level > MaxLevel {
+		return y, tsErrors.Errorf(tsErrors.InvalidPuzzle, fmt.Sprintf("level %d exceeds %d", init3.Level, MaxLevel))
+	}
+	n := new(big.Int).SetBytes(init3.N[:])
+	if n.Cmp(two) < 0 {
+		return y, tsErrors.Errorf(tsErrors.InvalidPuzzle, "modulus too small")
+	}
+
+	result := new(big.Int).SetBytes(init3.X[:])
+	result.Mod(result, n)
+	for i := uint32(0); i < init3.Level; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return y, err
+			}
+			if progress != nil && i > 0 {
+				progress(i, init3.Level)
+			}
+		}
+		result.Mul(result, result)
+		result.Mod(result, n)
+	}
+	if progress != nil {
+		progress(init3.Level, init3.Level)
+	}
+
+	result.FillBytes(y[:])
+	return y, nil
+}
+
+// SolveAll solves the puzzles of the Init3Packets in parallel with up to workers
+// goroutines, runtime.NumCPU() are used when workers is not positive. The answers
+// are in the order of init3s, the first error cancels the remaining puzzles.
+func SolveAll(ctx context.Context, init3s []*packets.Init3Packet, workers int, progress BatchProgressFunc) ([][64]byte, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(init3s) {
+		workers = len(init3s)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ys := make([][64]byte, len(init3s))
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		feedErr  error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				var solveProgress ProgressFunc
+				if progress != nil {
+					index := i
+					solveProgress = func(done, total uint32) {
+						progress(index, done, total)
+					}
+				}
+				y, err := Solve(ctx, init3s[i], solveProgress)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				ys[i] = y
+			}
+		}()
+	}
+
+feed:
+	for i := range init3s {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			feedErr = ctx.Err()
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if feedErr != nil {
+		return nil, feedErr
+	}
+	return ys, nil
+}
+
+// FillInit4 solves the puzzle of the Init3Packet and writes the echoed puzzle
+// and the answer into the Init4Packet
+func FillInit4(ctx context.Context, init3 *packets.Init3Packet, init4 *packets.Init4Packet, progress ProgressFunc) error {
+	y, err := Solve(ctx, init3, progress)
+	if err != nil {
+		return err
+	}
+	init4.X = init3.X
+	init4.N = init3.N
+	init4.Level = init3.Level
+	init4.Random2 = init3.Random2
+	init4.Y = y
+	return nil
+}
@@ -0,0 +1,135 @@
+package puzzle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+	"github.com/bzp2010/ts3protocol/tsproto/packets"
+)
+
+func TestSolve(t *testing.T) {
+	p, err := Generate(nil)
+	assert.NoError(t, err)
+	init3 := &packets.Init3Packet{}
+	p.FillInit3(init3, 2500)
+
+	var reports []uint32
+	init4 := &packets.Init4Packet{}
+	err = FillInit4(context.Background(), init3, init4, func(done, total uint32) {
+		assert.Equal(t, uint32(2500), total)
+		reports = append(reports, done)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1000, 2000, 2500}, reports)
+	assert.True(t, p.VerifyInit4(init4, 2500))
+}
+
+func TestSolveRejects(t *testing.T) {
+	p, err := Generate(nil)
+	assert.NoError(t, err)
+	init3 := &packets.Init3Packet{}
+
+	p.FillInit3(init3, MaxLevel+1)
+	_, err = Solve(context.Background(), init3, nil)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidPuzzle))
+
+	_, err = Solve(context.Background(), &packets.Init3Packet{Level: 10}, nil)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidPuzzle))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.FillInit3(init3, MaxLevel)
+	_, err = Solve(ctx, init3, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestSolveAll(t *testing.T) {
+	var (
+		puzzles []*Puzzle
+		init3s  []*packets.Init3Packet
+	)
+	for i := 0; i < 4; i++ {
+		p, err := Generate(nil)
+		assert.NoError(t, err)
+		init3 := &packets.Init3Packet{}
+		p.FillInit3(init3, 1500)
+		puzzles = append(puzzles, p)
+		init3s = append(init3s, init3)
+	}
+
+	var (
+		mu      sync.Mutex
+		reports = map[int][]uint32{}
+	)
+	ys, err := SolveAll(context.Background(), init3s, 2, func(index int, done, total uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports[index] = append(reports[index], done)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, ys, 4)
+	for i, p := range puzzles {
+		init4 := &packets.Init4Packet{X: init3s[i].X, N: init3s[i].N, Level: init3s[i].Level, Y: ys[i]}
+		assert.True(t, p.VerifyInit4(init4, 1500))
+		assert.Equal(t, []uint32{1000, 1500}, reports[i])
+	}
+
+	// the answers of an empty batch
+	ys, err = SolveAll(context.Background(), nil, 0, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, ys)
+}
+
+func TestSolveAllRejects(t *testing.T) {
+	p, err := Generate(nil)
+	assert.NoError(t, err)
+	valid := &packets.Init3Packet{}
+	p.FillInit3(valid, MaxLevel)
+	hostile := &packets.Init3Packet{}
+	p.FillInit3(hostile, MaxLevel+1)
+
+	// a bad puzzle cancels the others
+	_, err = SolveAll(context.Background(), []*packets.Init3Packet{valid, hostile, valid}, 3, nil)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidPuzzle))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SolveAll(ctx, []*packets.Init3Packet{valid, valid}, 1, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func BenchmarkSolve(b *testing.B) {
+	p, _ := Generate(nil)
+	for _, level := range []uint32{1000, DefaultLevel, 100000} {
+		init3 := &packets.Init3Packet{}
+		p.FillInit3(init3, level)
+		b.Run(fmt.Sprintf("level-%d", level), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = Solve(context.Background(), init3, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkSolveAll(b *testing.B) {
+	var init3s []*packets.Init3Packet
+	for i := 0; i < 8; i++ {
+		p, _ := Generate(nil)
+		init3 := &packets.Init3Packet{}
+		p.FillInit3(init3, DefaultLevel)
+		init3s = append(init3s, init3)
+	}
+	for _, workers := range []int{1, 4, 0} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = SolveAll(context.Background(), init3s, workers, nil)
+			}
+		})
+	}
+}
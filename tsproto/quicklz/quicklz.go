@@ -0,0 +1,302 @@
+// Package quicklz implements the QuickLZ 1.5.0 level 1 format without streaming buffer,
+// which is used by TeamSpeak 3 for compressing command payload.
+package quicklz
+
+import (
+	"encoding/binary"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+const (
+	level                 = 1
+	hashValues            = 4096
+	cwordLen              = 4
+	unconditionalMatchLen = 6
+	uncompressedEnd       = 4
+	maxMatchLen           = 255
+	// inputs shorter than this use the 3 bytes header, otherwise the 9 bytes one
+	shortHeaderLimit = 216
+
+	flagCompressed = 0x01
+	flagLongHeader = 0x02
+	flagAlwaysSet  = 0x40
+)
+
+// hashTable maps the hash of 3 bytes to the last position holding them,
+// positions are stored with an offset of 1 so the zero value means unset
+type hashTable [hashValues]int
+
+func hashFunc(fetch uint32) int {
+	return int(((fetch >> 12) ^ fetch) & (hashValues - 1))
+}
+
+func read3(data []byte, i int) uint32 {
+	return uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16
+}
+
+// Compress compresses data with QuickLZ level 1 and produces the same output as the
+// reference implementation, data with a poor compression ratio is stored uncompressed
+func Compress(data []byte) []byte {
+	headerLen := 3
+	if len(data) >= shortHeaderLimit {
+		headerLen = 9
+	}
+
+	body := compressCore(data)
+	compressed := body != nil
+	if !compressed {
+		body = data
+	}
+
+	out := make([]byte, headerLen, headerLen+len(body))
+	out = append(out, body...)
+
+	out[0] = flagAlwaysSet | level<<2
+	if compressed {
+		out[0] |= flagCompressed
+	}
+	if headerLen == 9 {
+		out[0] |= flagLongHeader
+		binary.LittleEndian.PutUint32(out[1:5], uint32(len(out)))
+		binary.LittleEndian.PutUint32(out[5:9], uint32(len(data)))
+	} else {
+		out[1] = byte(len(out))
+		out[2] = byte(len(data))
+	}
+	return out
+}
+
+// same reports whether the n bytes following data[0] are all equal to it
+func same(data []byte, n int) bool {
+	for i := 1; i <= n; i++ {
+		if data[i] != data[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// compressCore returns nil when the compression ratio is too low
+func compressCore(src []byte) []byte {
+	var (
+		table          hashTable
+		size           = len(src)
+		lastMatchStart = size - 1 - unconditionalMatchLen - uncompressedEnd
+		dst            = make([]byte, cwordLen, size+size/31*cwordLen+2*cwordLen)
+		cwordPos       = 0
+		cword          = uint32(1) << 31
+		pos            = 0
+		lits           = 0
+	)
+
+	// every control word carries 31 items, literal is 0 and match is 1
+	nextItem := func() {
+		if cword&1 == 1 {
+			binary.LittleEndian.PutUint32(dst[cwordPos:], cword>>1|1<<31)
+			cwordPos = len(dst)
+			dst = append(dst, 0, 0, 0, 0)
+			cword = 1 << 31
+		}
+	}
+
+	for pos <= lastMatchStart {
+		if cword&1 == 1 && pos > size>>1 && len(dst) > pos-pos>>5 {
+			return nil
+		}
+		nextItem()
+
+		fetch := read3(src, pos)
+		hash := hashFunc(fetch)
+		o := table[hash] - 1
+		table[hash] = pos + 1
+
+		// the decompressor only knows the positions at least 3 bytes behind,
+		// a run of the same byte is the exception since an older position has the same bytes
+		if o >= 0 && read3(src, o) == fetch &&
+			(pos-o > 2 || (pos == o+1 && lits >= 3 && pos > 3 && same(src[pos-3:], 6))) {
+			limit := size - uncompressedEnd - pos
+			if limit > maxMatchLen {
+				limit = maxMatchLen
+			}
+			matchLen := 3
+			for matchLen < limit && src[o+matchLen] == src[pos+matchLen] {
+				matchLen++
+			}
+
+			cword = cword>>1 | 1<<31
+			if matchLen < 18 {
+				dst = append(dst, byte(matchLen-2)|byte(hash<<4), byte(hash>>4))
+			} else {
+				dst = append(dst, byte(hash<<4), byte(hash>>4), byte(matchLen))
+			}
+			pos += matchLen
+			lits = 0
+			continue
+		}
+
+		cword >>= 1
+		dst = append(dst, src[pos])
+		pos++
+		lits++
+	}
+
+	for pos < size {
+		nextItem()
+		cword >>= 1
+		dst = append(dst, src[pos])
+		pos++
+	}
+
+	for cword&1 != 1 {
+		cword >>= 1
+	}
+	binary.LittleEndian.PutUint32(dst[cwordPos:], cword>>1|1<<31)
+
+	// the reference implementation never produces less than 9 bytes
+	for len(dst) < 9 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// Decompress decompresses QuickLZ level 1 data, the decompressed size declared in
+// the header must not exceed maxSize
+func Decompress(data []byte, maxSize int) ([]byte, error) {
+	if len(data) < 3 {
+		return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "header incomplete")
+	}
+
+	var (
+		flags          = data[0]
+		headerLen      = 3
+		compressedSize int
+		size           int
+	)
+	if (flags>>2)&0x03 != level {
+		return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "unsupported compression level")
+	}
+	if flags&flagLongHeader != 0 {
+		headerLen = 9
+		if len(data) < headerLen {
+			return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "header incomplete")
+		}
+		compressedSize = int(binary.LittleEndian.Uint32(data[1:5]))
+		size = int(binary.LittleEndian.Uint32(data[5:9]))
+	} else {
+		compressedSize = int(data[1])
+		size = int(data[2])
+	}
+	if compressedSize != len(data) {
+		return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "compressed size mismatch")
+	}
+	if size < 0 || size > maxSize {
+		return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "decompressed size exceeds limit")
+	}
+
+	if flags&flagCompressed == 0 {
+		if len(data)-headerLen != size {
+			return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "stored size mismatch")
+		}
+		return append([]byte{}, data[headerLen:]...), nil
+	}
+
+	return decompressCore(data[headerLen:], size)
+}
+
+func decompressCore(src []byte, size int) ([]byte, error) {
+	var (
+		table          hashTable
+		out            = make([]byte, size)
+		lastMatchStart = size - 1 - unconditionalMatchLen - uncompressedEnd
+		lastHashed     = -1
+		cword          = uint32(1)
+		pos            = 0
+		dst            = 0
+	)
+
+	updateHashUpTo := func(max int) {
+		for lastHashed < max {
+			lastHashed++
+			table[hashFunc(read3(out, lastHashed))] = lastHashed + 1
+		}
+	}
+	incomplete := tsErrors.Errorf(tsErrors.InvalidQuickLZ, "data incomplete")
+
+	for {
+		if cword == 1 {
+			if pos+cwordLen > len(src) {
+				return nil, incomplete
+			}
+			cword = binary.LittleEndian.Uint32(src[pos:])
+			pos += cwordLen
+		}
+
+		if cword&1 == 1 {
+			cword >>= 1
+			if pos+2 > len(src) {
+				return nil, incomplete
+			}
+			fetch := uint32(src[pos]) | uint32(src[pos+1])<<8
+			hash := int(fetch>>4) & (hashValues - 1)
+
+			var matchLen int
+			if fetch&0x0f != 0 {
+				matchLen = int(fetch&0x0f) + 2
+				pos += 2
+			} else {
+				if pos+3 > len(src) {
+					return nil, incomplete
+				}
+				matchLen = int(src[pos+2])
+				pos += 3
+			}
+
+			o := table[hash] - 1
+			if o < 0 || o >= dst {
+				return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "invalid match offset")
+			}
+			if matchLen < 3 || matchLen > size-dst-uncompressedEnd {
+				return nil, tsErrors.Errorf(tsErrors.InvalidQuickLZ, "invalid match length")
+			}
+
+			// copy byte by byte, the source may overlap the destination
+			for i := 0; i < matchLen; i++ {
+				out[dst+i] = out[o+i]
+			}
+			dst += matchLen
+
+			updateHashUpTo(dst - matchLen)
+			lastHashed = dst - 1
+			continue
+		}
+
+		if dst < lastMatchStart {
+			if pos >= len(src) {
+				return nil, incomplete
+			}
+			out[dst] = src[pos]
+			dst++
+			pos++
+			cword >>= 1
+			updateHashUpTo(dst - 3)
+			continue
+		}
+
+		// the remaining bytes are all literals
+		for dst < size {
+			if cword == 1 {
+				pos += cwordLen
+				cword = 1 << 31
+			}
+			if pos >= len(src) {
+				return nil, incomplete
+			}
+			out[dst] = src[pos]
+			dst++
+			pos++
+			cword >>= 1
+		}
+		return out, nil
+	}
+}
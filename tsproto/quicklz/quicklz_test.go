@@ -0,0 +1,167 @@
+package quicklz
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tsErrors "github.com/bzp2010/ts3protocol/tsproto/errors"
+)
+
+func TestCompressVectors(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  []byte
+		expect []byte
+	}{
+		{
+			// 6 literals, one match of 14 bytes and 4 trailing literals
+			name:  "compressible",
+			input: []byte("abcdefabcdefabcdefabcdef"),
+			expect: []byte{
+				0x45, 0x13, 0x18,
+				0x40, 0x00, 0x00, 0x80,
+				'a', 'b', 'c', 'd', 'e', 'f',
+				0x7c, 0x45,
+				'c', 'd', 'e', 'f',
+			},
+		},
+		{
+			// short inputs are always literals but still flagged as compressed
+			name:  "incompressible short",
+			input: []byte("0123456789"),
+			expect: []byte{
+				0x45, 0x11, 0x0a,
+				0x00, 0x00, 0x00, 0x80,
+				'0', '1', '2', '3', '4', '5', '6', '7', '8', '9',
+			},
+		},
+	}
+	for _, c := range cases {
+		compressed := Compress(c.input)
+		assert.Equal(t, c.expect, compressed, c.name)
+
+		decompressed, err := Decompress(c.expect, 1024)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.input, decompressed, c.name)
+	}
+}
+
+func TestCompressIncompressible(t *testing.T) {
+	input := make([]byte, 300)
+	rand.New(rand.NewSource(4)).Read(input)
+
+	compressed := Compress(input)
+	// stored with the long header and without the compressed flag
+	assert.Equal(t, byte(0x46), compressed[0])
+	assert.Equal(t, []byte{0x35, 0x01, 0x00, 0x00, 0x2c, 0x01, 0x00, 0x00}, compressed[1:9])
+	assert.Equal(t, input, compressed[9:])
+
+	decompressed, err := Decompress(compressed, len(input))
+	assert.NoError(t, err)
+	assert.Equal(t, input, decompressed)
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	inputs := [][]byte{
+		{},
+		[]byte("a"),
+		bytes.Repeat([]byte{0x00}, 1000),
+		bytes.Repeat([]byte("xy"), 400),
+		[]byte(strings.Repeat("channellist cid=1 cpid=0 channel_name=Default\\sChannel channel_order=0|", 50)),
+	}
+	for i := 0; i < 50; i++ {
+		// mostly compressible random data
+		input := make([]byte, r.Intn(5000))
+		for j := range input {
+			input[j] = byte('a' + r.Intn(4))
+		}
+		inputs = append(inputs, input)
+	}
+
+	for _, input := range inputs {
+		compressed := Compress(input)
+		decompressed, err := Decompress(compressed, len(input))
+		assert.NoError(t, err)
+		assert.Equal(t, len(input), len(decompressed))
+		assert.True(t, bytes.Equal(input, decompressed))
+	}
+
+	compressed := Compress(inputs[4])
+	assert.Equal(t, byte(0x47), compressed[0])
+	assert.Less(t, len(compressed), len(inputs[4])/4)
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	valid := Compress([]byte("abcdefabcdefabcdefabcdef"))
+
+	for _, data := range [][]byte{
+		{},
+		{0x45},
+		valid[:len(valid)-1],
+		append(append([]byte{}, valid...), 0x00),
+		{0x4d, 0x03, 0x00}, // level 3
+		{0x47, 0x09, 0x00, 0x00, 0x00},
+	} {
+		_, err := Decompress(data, 1024)
+		assert.True(t, errors.Is(err, tsErrors.ErrInvalidQuickLZ))
+	}
+
+	_, err := Decompress(valid, 10)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidQuickLZ))
+
+	// match referring to an unknown hash
+	broken := append([]byte{}, valid...)
+	broken[13] = 0x7c ^ 0x10
+	_, err = Decompress(broken, 1024)
+	assert.True(t, errors.Is(err, tsErrors.ErrInvalidQuickLZ))
+}
+
+func FuzzDecompress(f *testing.F) {
+	f.Add(Compress([]byte("abcdefabcdefabcdefabcdef")))
+	f.Add(Compress(bytes.Repeat([]byte("xy"), 400)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := Decompress(data, 1<<16)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(Compress(out), Compress(out)) {
+			t.Fatal("unstable compression")
+		}
+	})
+}
+
+func FuzzCompressRoundTrip(f *testing.F) {
+	f.Add([]byte("abcdefabcdefabcdefabcdef"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := Decompress(Compress(data), len(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatal("round trip mismatch")
+		}
+	})
+}
+
+func BenchmarkCompress(b *testing.B) {
+	input := []byte(strings.Repeat("channellist cid=1 cpid=0 channel_name=Default\\sChannel channel_order=0|", 50))
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		Compress(input)
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	input := []byte(strings.Repeat("channellist cid=1 cpid=0 channel_name=Default\\sChannel channel_order=0|", 50))
+	compressed := Compress(input)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		_, _ = Decompress(compressed, len(input))
+	}
+}